@@ -0,0 +1,144 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// manifestEntry describes one file in a bundle.
+type manifestEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// writeBundle writes a gzip-compressed tarball holding files and, unless
+// coverDir is empty, the images in coverDir under covers/. A manifest.json
+// listing every file with its size and SHA-256 comes first, at the archive
+// root. Files are streamed into the archive, not copied beforehand. A file
+// listed twice is stored once; two files that would get the same name in the
+// archive are an error.
+func writeBundle(filename string, files []string, coverDir string) error {
+	type member struct{ path, name string }
+	var members []member
+	paths := map[string]string{"manifest.json": "the manifest"}
+	add := func(path, name string) error {
+		if prev, ok := paths[name]; ok {
+			if prev == filepath.Clean(path) {
+				return nil
+			}
+			return fmt.Errorf("%s and %s would both be stored as %s", prev, path, name)
+		}
+		paths[name] = filepath.Clean(path)
+		members = append(members, member{path, name})
+		return nil
+	}
+	for _, f := range files {
+		if err := add(f, filepath.Base(f)); err != nil {
+			return err
+		}
+	}
+	if coverDir != "" {
+		entries, err := os.ReadDir(coverDir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				if err := add(filepath.Join(coverDir, entry.Name()), "covers/"+entry.Name()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	manifest := make([]manifestEntry, 0, len(members))
+	for _, m := range members {
+		entry, err := describeFile(m.path)
+		if err != nil {
+			return err
+		}
+		entry.Name = m.name
+		manifest = append(manifest, entry)
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+
+	if err := tw.WriteHeader(&tar.Header{Name: "manifest.json", Mode: 0o644, Size: int64(len(manifestData)), ModTime: time.Now()}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(manifestData); err != nil {
+		return err
+	}
+	for i, m := range members {
+		if err := addToTar(tw, m.path, m.name, manifest[i].Size); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// describeFile returns the size and SHA-256 of the file at path.
+func describeFile(path string) (manifestEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return manifestEntry{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return manifestEntry{}, err
+	}
+	return manifestEntry{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// addToTar copies the file at path into tw as name. size must match the
+// file's length.
+func addToTar(tw *tar.Writer, path, name string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: info.ModTime()}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readBundle returns the member names of a bundle and its manifest.
+func readBundle(t *testing.T, filename string) ([]string, []manifestEntry) {
+	t.Helper()
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+
+	var names []string
+	var manifest []manifestEntry
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+		if header.Name == "manifest.json" {
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return names, manifest
+}
+
+func TestWriteBundle(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, data string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	list := writeFile("list.csv", "Title\n")
+	cloud := writeFile("cloud.csv", "category,weight\n")
+	writeFile("covers/1.jpg", "jpeg")
+
+	bundle := filepath.Join(dir, "out.tar.gz")
+	if err := writeBundle(bundle, []string{list, cloud, list, dir + "/./list.csv"}, filepath.Join(dir, "covers")); err != nil {
+		t.Fatal(err)
+	}
+	names, manifest := readBundle(t, bundle)
+	if want := []string{"manifest.json", "list.csv", "cloud.csv", "covers/1.jpg"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("bundle members = %q, want %q", names, want)
+	}
+	var listed []string
+	for _, entry := range manifest {
+		listed = append(listed, entry.Name)
+	}
+	if want := []string{"list.csv", "cloud.csv", "covers/1.jpg"}; !reflect.DeepEqual(listed, want) {
+		t.Errorf("manifest entries = %q, want %q", listed, want)
+	}
+
+	other := writeFile("other/list.csv", "Title\n")
+	if err := writeBundle(bundle, []string{list, other}, ""); err == nil || !strings.Contains(err.Error(), "list.csv") {
+		t.Errorf("writeBundle with two list.csv files = %v, want a name clash error", err)
+	}
+	manifestFile := writeFile("manifest.json", "{}")
+	if err := writeBundle(bundle, []string{manifestFile}, ""); err == nil {
+		t.Error("writeBundle accepted an output named manifest.json")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// brokenCover is a product whose cover image could not be verified.
+type brokenCover struct {
+	Product Product
+	Problem string
+}
+
+// verifyCovers sends a HEAD request for every product's cover image and
+// returns the products whose cover is missing, unreachable or not an image.
+// Requests go through the shared host limiter and retry policy. If ctx ends
+// first, the covers checked so far are returned; checked says how many.
+func verifyCovers(ctx context.Context, products []Product) (broken []brokenCover, checked int) {
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	sem := make(chan struct{}, maxConcurrent)
+
+	for _, product := range products {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+
+		go func(product Product) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			problem := checkCover(ctx, product.CoverImage)
+			if ctx.Err() != nil {
+				return // cut short, not known to be broken
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			checked++
+			if problem != "" {
+				broken = append(broken, brokenCover{Product: product, Problem: problem})
+			}
+		}(product)
+	}
+	wg.Wait()
+
+	return broken, checked
+}
+
+// checkCover returns a description of what is wrong with the cover at
+// coverURL, or "" if it is a reachable image.
+func checkCover(ctx context.Context, coverURL string) string {
+	if coverURL == "" {
+		return "no cover URL"
+	}
+
+	req, err := newRequest(ctx, "HEAD", coverURL)
+	if err != nil {
+		return err.Error()
+	}
+
+	release := hosts.acquire(req.URL.Host)
+	defer release()
+
+	resp, err := doWithRetry(&http.Client{}, req)
+	if err != nil {
+		return err.Error()
+	}
+	resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "image/") {
+		return fmt.Sprintf("not an image (Content-Type %q)", contentType)
+	}
+	return ""
+}
+
+// writeBrokenCovers writes the products with broken covers as CSV.
+func writeBrokenCovers(filename string, broken []brokenCover) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newCSVWriter(file)
+	defer writer.Flush()
+
+	header := []string{"Title", "URL", "Cover Image", "Problem"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, b := range broken {
+		row := []string{b.Product.Title, b.Product.URL, b.Product.CoverImage, b.Problem}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("%d broken covers written to %s", len(broken), filename)
+	return nil
+}
+
+// coverDownloads counts the outcomes of downloadCovers.
+type coverDownloads struct {
+	Downloaded, Present, NoCover, Failed int
+}
+
+// coverExtensions maps image content types to file extensions.
+var coverExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// unsafeFileChars matches characters not kept in cover file names.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// downloadCovers saves each product's cover image in dir as <product id>.<ext>,
+// skipping products that already have a cover file there, so an interrupted
+// run can be resumed. Images are written under a temporary name and renamed
+// when complete, so a partial download is never mistaken for a present one.
+// It stops dispatching when ctx ends.
+func downloadCovers(ctx context.Context, products []Product, dir string) (coverDownloads, error) {
+	var counts coverDownloads
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return counts, err
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	sem := make(chan struct{}, maxConcurrent)
+
+	for _, product := range products {
+		name := unsafeFileChars.ReplaceAllString(product.ProductID, "_")
+		if product.CoverImage == "" || name == "" {
+			counts.NoCover++
+			continue
+		}
+		if existing, _ := filepath.Glob(filepath.Join(dir, name+".*")); len(existing) > 0 {
+			counts.Present++
+			continue
+		}
+
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+
+		go func(product Product, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := downloadCover(ctx, product.CoverImage, dir, name)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Printf("Error downloading cover for %q: %v", product.Title, err)
+				counts.Failed++
+				return
+			}
+			counts.Downloaded++
+		}(product, name)
+	}
+	wg.Wait()
+
+	return counts, nil
+}
+
+// downloadCover fetches coverURL into dir/name.<ext>. A response that is not
+// an image is an error and nothing is saved.
+func downloadCover(ctx context.Context, coverURL, dir, name string) error {
+	req, err := newRequest(ctx, "GET", coverURL)
+	if err != nil {
+		return err
+	}
+
+	release := hosts.acquire(req.URL.Host)
+	defer release()
+
+	resp, err := doWithRetry(&http.Client{}, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Login and error pages come back as 200 too; saving one would make
+	// the next run count the cover as present.
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		return fmt.Errorf("not an image (Content-Type %q)", contentType)
+	}
+	ext := coverExtensions[strings.Split(contentType, ";")[0]]
+	if ext == "" {
+		ext = path.Ext(req.URL.Path)
+	}
+	if ext == "" {
+		ext = ".img"
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+name+"-*.part")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// CreateTemp makes the file 0600; give covers the mode of other outputs.
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name+ext))
+}
+
+// readProductsJSON reads products from a file written by the json format.
+func readProductsJSON(filename string) ([]Product, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var products []Product
+	if err := json.Unmarshal(data, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestDownloadCovers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cover.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write([]byte("jpeg"))
+		case "/login":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html>sign in</html>"))
+		case "/untyped":
+			w.Header().Set("Content-Type", "")
+			w.Write([]byte{0x89, 'P', 'N', 'G'})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	products := []Product{
+		{ProductID: "good", CoverImage: srv.URL + "/cover.jpg"},
+		{ProductID: "html", CoverImage: srv.URL + "/login"},
+		{ProductID: "untyped", CoverImage: srv.URL + "/untyped"},
+		{ProductID: "missing", CoverImage: srv.URL + "/missing.jpg"},
+		{ProductID: "none"},
+	}
+	dir := t.TempDir()
+
+	counts, err := downloadCovers(context.Background(), products, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (coverDownloads{Downloaded: 1, NoCover: 1, Failed: 3}); counts != want {
+		t.Errorf("first run counts = %+v, want %+v", counts, want)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	if len(names) != 1 || names[0] != "good.jpg" {
+		t.Errorf("saved files = %q, want only good.jpg", names)
+	}
+	info, err := os.Stat(filepath.Join(dir, "good.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o644 {
+		t.Errorf("good.jpg mode = %o, want 644", mode)
+	}
+
+	// A resumed run skips the saved cover and tries the failed ones again.
+	counts, err = downloadCovers(context.Background(), products, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (coverDownloads{Present: 1, NoCover: 1, Failed: 3}); counts != want {
+		t.Errorf("second run counts = %+v, want %+v", counts, want)
+	}
+}
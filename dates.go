@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// outputLoc is the time zone dates are rendered in, set by -timezone.
+var outputLoc = time.Local
+
+// publicationDateLayouts are the forms publication_date has been seen in.
+var publicationDateLayouts = []string{
+	"2006-01-02",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+}
+
+// parsePublicationDate parses a publication_date value. Values without a zone
+// are taken as UTC.
+func parsePublicationDate(s string) (time.Time, bool) {
+	for _, layout := range publicationDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// publicationTime returns a product's publication date in outputLoc. A plain
+// calendar date has no time to shift and is returned as that date.
+func publicationTime(product Product) (time.Time, bool) {
+	s := product.CustomAttributes.PublicationDate
+	t, ok := parsePublicationDate(s)
+	if !ok {
+		return time.Time{}, false
+	}
+	if len(s) == len("2006-01-02") {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, outputLoc), true
+	}
+	return t.In(outputLoc), true
+}
+
+// displayDate renders a product's publication date as YYYY-MM-DD in
+// outputLoc. Dates that do not parse are kept as they are.
+func displayDate(product Product) string {
+	t, ok := publicationTime(product)
+	if !ok {
+		return product.CustomAttributes.PublicationDate
+	}
+	return t.Format("2006-01-02")
+}
+
+// monthGroup is the set of products published in one month.
+type monthGroup struct {
+	Title    string    // e.g. "June 2024", or "Unknown"
+	Month    time.Time // first day of the month; zero for "Unknown"
+	Products []Product
+}
+
+// groupByMonth buckets products by publication month, newest month first,
+// keeping input order within a month. Products without a usable date are
+// collected in a final "Unknown" group.
+func groupByMonth(products []Product) []monthGroup {
+	var (
+		groups  []monthGroup
+		index   = make(map[time.Time]int)
+		unknown []Product
+	)
+	for _, product := range products {
+		t, ok := publicationTime(product)
+		if !ok {
+			unknown = append(unknown, product)
+			continue
+		}
+		month := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		i, ok := index[month]
+		if !ok {
+			i = len(groups)
+			index[month] = i
+			groups = append(groups, monthGroup{Title: month.Format("January 2006"), Month: month})
+		}
+		groups[i].Products = append(groups[i].Products, product)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Month.After(groups[j].Month) })
+	if len(unknown) > 0 {
+		groups = append(groups, monthGroup{Title: "Unknown", Products: unknown})
+	}
+	return groups
+}
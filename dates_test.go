@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// setOutputLoc sets outputLoc for the rest of the test.
+func setOutputLoc(t *testing.T, loc *time.Location) {
+	t.Helper()
+	old := outputLoc
+	outputLoc = loc
+	t.Cleanup(func() { outputLoc = old })
+}
+
+func TestGroupByMonth(t *testing.T) {
+	products := []Product{
+		datedProduct("may", "2024-05-15"),
+		datedProduct("undated", ""),
+		datedProduct("late-june-utc", "2024-06-30T23:30:00Z"),
+		datedProduct("early-july-utc", "2024-07-01T02:00:00Z"),
+		datedProduct("july-date", "2024-07-01"),
+		datedProduct("garbled", "someday"),
+		datedProduct("may-again", "2024-05-01"),
+	}
+	grouped := func() map[string][]string {
+		got := make(map[string][]string)
+		for _, group := range groupByMonth(products) {
+			for _, product := range group.Products {
+				got[group.Title] = append(got[group.Title], product.ProductID)
+			}
+		}
+		return got
+	}
+	titles := func() []string {
+		var got []string
+		for _, group := range groupByMonth(products) {
+			got = append(got, group.Title)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name   string
+		loc    *time.Location
+		titles []string
+		groups map[string][]string
+	}{
+		{"UTC", time.UTC, []string{"July 2024", "June 2024", "May 2024", "Unknown"}, map[string][]string{
+			"July 2024": {"early-july-utc", "july-date"},
+			"June 2024": {"late-june-utc"},
+			"May 2024":  {"may", "may-again"},
+			"Unknown":   {"undated", "garbled"},
+		}},
+		{"behind UTC", time.FixedZone("UTC-5", -5*60*60), []string{"July 2024", "June 2024", "May 2024", "Unknown"}, map[string][]string{
+			"July 2024": {"july-date"},
+			"June 2024": {"late-june-utc", "early-july-utc"},
+			"May 2024":  {"may", "may-again"},
+			"Unknown":   {"undated", "garbled"},
+		}},
+		{"ahead of UTC", time.FixedZone("UTC+9", 9*60*60), []string{"July 2024", "May 2024", "Unknown"}, map[string][]string{
+			"July 2024": {"late-june-utc", "early-july-utc", "july-date"},
+			"May 2024":  {"may", "may-again"},
+			"Unknown":   {"undated", "garbled"},
+		}},
+	}
+	for _, test := range tests {
+		setOutputLoc(t, test.loc)
+		if got := titles(); !reflect.DeepEqual(got, test.titles) {
+			t.Errorf("%s: sections = %q, want %q", test.name, got, test.titles)
+		}
+		if got := grouped(); !reflect.DeepEqual(got, test.groups) {
+			t.Errorf("%s: groups = %q, want %q", test.name, got, test.groups)
+		}
+	}
+
+	if got := groupByMonth(nil); len(got) != 0 {
+		t.Errorf("groupByMonth(nil) = %v, want no groups", got)
+	}
+}
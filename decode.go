@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+)
+
+// decodeResponseStream decodes a search response from r, handing each element
+// of data.products to emit as soon as it is parsed. The returned Response
+// carries everything except the products. Unknown fields are skipped.
+func decodeResponseStream(r io.Reader, emit func(Product)) (Response, error) {
+	var response Response
+	dec := json.NewDecoder(r)
+
+	err := decodeObject(dec, func(key string) error {
+		switch key {
+		case "message":
+			return dec.Decode(&response.Message)
+		case "data":
+			return decodeObject(dec, func(key string) error {
+				switch key {
+				case "products":
+					return decodeArray(dec, func() error {
+						var product Product
+						if err := dec.Decode(&product); err != nil {
+							return err
+						}
+						emit(product)
+						return nil
+					})
+				case "total":
+					return dec.Decode(&response.Data.Total)
+				case "start":
+					return dec.Decode(&response.Data.Start)
+				case "facets":
+					return dec.Decode(&response.Data.Facets)
+				}
+				return skipValue(dec)
+			})
+		}
+		return skipValue(dec)
+	})
+	if err != nil {
+		return Response{}, err
+	}
+
+	return response, nil
+}
+
+// decodeObject reads a JSON object, calling field with the decoder positioned
+// at each member's value. field must consume the value. A null is treated as
+// an empty object.
+func decodeObject(dec *json.Decoder, field func(key string) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expected object, got %v", tok)
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("expected object key, got %v", tok)
+		}
+		if err := field(key); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	_, err = dec.Token() // closing '}'
+	return err
+}
+
+// decodeArray reads a JSON array, calling elem once per element with the
+// decoder positioned at it. elem must consume the element. A null is treated
+// as an empty array.
+func decodeArray(dec *json.Decoder, elem func() error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected array, got %v", tok)
+	}
+
+	for dec.More() {
+		if err := elem(); err != nil {
+			return err
+		}
+	}
+
+	_, err = dec.Token() // closing ']'
+	return err
+}
+
+// skipValue consumes the next JSON value without keeping it.
+func skipValue(dec *json.Decoder) error {
+	var discard json.RawMessage
+	return dec.Decode(&discard)
+}
+
+// unescapeHTML decodes HTML entities such as &amp; and &#39; in the product's
+// text fields, which the API sometimes sends escaped.
+func unescapeHTML(product *Product) {
+	unescapeAll := func(values []string) {
+		for i, v := range values {
+			values[i] = html.UnescapeString(v)
+		}
+	}
+
+	product.Title = html.UnescapeString(product.Title)
+	product.Description = html.UnescapeString(product.Description)
+	for _, category := range product.Categories {
+		unescapeAll(category)
+	}
+	unescapeAll(product.CustomAttributes.Publishers)
+	unescapeAll(product.Authors)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnescapeHTML(t *testing.T) {
+	var product Product
+	product.Title = "Tom &amp; Jerry&#39;s Guide to &lt;Go&gt;"
+	product.Description = "Double-escaped &amp;amp; stays single-escaped"
+	product.URL = "https://example.com/?a=1&amp;b=2"
+	product.Categories = [][]string{{"R&amp;D", "Q&amp;A"}, {}, {"Caf&eacute;"}}
+	product.CustomAttributes.Publishers = []string{"O&#39;Reilly Media, Inc."}
+	product.Authors = []string{"Ren&eacute;e O&#39;Brien", "Plain Name"}
+
+	unescapeHTML(&product)
+
+	if want := "Tom & Jerry's Guide to <Go>"; product.Title != want {
+		t.Errorf("title = %q, want %q", product.Title, want)
+	}
+	if want := "Double-escaped &amp; stays single-escaped"; product.Description != want {
+		t.Errorf("description = %q, want %q", product.Description, want)
+	}
+	if want := "https://example.com/?a=1&amp;b=2"; product.URL != want {
+		t.Errorf("URL = %q, want it left alone", product.URL)
+	}
+	if want := [][]string{{"R&D", "Q&A"}, {}, {"Café"}}; !reflect.DeepEqual(product.Categories, want) {
+		t.Errorf("categories = %q, want %q", product.Categories, want)
+	}
+	if want := []string{"O'Reilly Media, Inc."}; !reflect.DeepEqual(product.CustomAttributes.Publishers, want) {
+		t.Errorf("publishers = %q, want %q", product.CustomAttributes.Publishers, want)
+	}
+	if want := []string{"Renée O'Brien", "Plain Name"}; !reflect.DeepEqual(product.Authors, want) {
+		t.Errorf("authors = %q, want %q", product.Authors, want)
+	}
+}
@@ -0,0 +1,114 @@
+//go:build duckdb
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/marcboeker/go-duckdb"
+)
+
+func init() {
+	outputFormats["duckdb"] = outputFormat{"DuckDB", "duckdb", writeDuckDB}
+}
+
+// createProductsTable is the schema of the DuckDB products table. Each
+// category path is stored as one "Top > Sub" string.
+const createProductsTable = `CREATE TABLE products (
+	product_id       VARCHAR,
+	title            VARCHAR,
+	publication_date DATE,
+	url              VARCHAR,
+	type             VARCHAR,
+	format           VARCHAR,
+	language         VARCHAR,
+	categories       VARCHAR[],
+	cover_image      VARCHAR,
+	publishers       VARCHAR[],
+	authors          VARCHAR[],
+	description      VARCHAR,
+	content_hash     VARCHAR
+)`
+
+// writeDuckDB writes products into a products table in a new DuckDB database
+// file, replacing any existing file. Unparsable publication dates and content
+// hashes not computed by -content-hash are stored as NULL.
+func writeDuckDB(filename string, products []Product) error {
+	if err := os.Remove(filename); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	connector, err := duckdb.NewConnector(filename, nil)
+	if err != nil {
+		return err
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	if _, err := db.Exec(createProductsTable); err != nil {
+		return err
+	}
+
+	conn, err := connector.Connect(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	appender, err := duckdb.NewAppenderFromConn(conn, "", "products")
+	if err != nil {
+		return err
+	}
+	for _, product := range products {
+		// DuckDB converts a time to UTC before taking its date, so pass
+		// the calendar date in outputLoc as UTC midnight.
+		var published any
+		if t, ok := publicationTime(product); ok {
+			published = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		var hash any
+		if product.ContentHash != "" {
+			hash = product.ContentHash
+		}
+		categories := make([]string, 0, len(product.Categories))
+		for _, category := range product.Categories {
+			categories = append(categories, strings.Join(category, " > "))
+		}
+
+		err := appender.AppendRow(
+			product.ProductID,
+			product.Title,
+			published,
+			product.URL,
+			product.Type,
+			classifyFormat(product),
+			product.Language,
+			categories,
+			product.CoverImage,
+			nonNil(product.CustomAttributes.Publishers),
+			nonNil(product.Authors),
+			product.Description,
+			hash,
+		)
+		if err != nil {
+			appender.Close()
+			return err
+		}
+	}
+	return appender.Close()
+}
+
+// nonNil returns values, or an empty list if it is nil, so missing lists are
+// stored as [] rather than NULL.
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
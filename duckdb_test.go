@@ -0,0 +1,88 @@
+//go:build duckdb
+
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteDuckDB(t *testing.T) {
+	setOutputLoc(t, time.FixedZone("UTC+9", 9*60*60))
+
+	dated := datedProduct("1", "2024-06-01", "Ann", "Bob")
+	dated.Title = "Learning Go"
+	dated.Type = "book"
+	dated.Categories = [][]string{{"Programming", "Go"}, {"Data"}}
+	dated.CustomAttributes.Publishers = []string{"O'Reilly"}
+	dated.ContentHash = "abc123"
+	undated := datedProduct("2", "someday")
+
+	filename := filepath.Join(t.TempDir(), "products.duckdb")
+	if err := writeDuckDB(filename, []Product{dated, undated}); err != nil {
+		t.Fatal(err)
+	}
+	// Writing again replaces the file rather than appending to it.
+	if err := writeDuckDB(filename, []Product{dated, undated}); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("duckdb", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT product_id, strftime(publication_date, '%Y-%m-%d'), len(categories),
+		categories[1], len(publishers), len(authors), format, content_hash
+		FROM products ORDER BY product_id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	type row struct {
+		id                  string
+		date                sql.NullString
+		categories          int
+		firstCategory       string
+		publishers, authors int
+		format              string
+		hash                sql.NullString
+	}
+	want := []row{
+		{"1", sql.NullString{String: "2024-06-01", Valid: true}, 2, "Programming > Go", 1, 2, formatEbook, sql.NullString{String: "abc123", Valid: true}},
+		{"2", sql.NullString{}, 0, "", 0, 0, formatOther, sql.NullString{}},
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		var first sql.NullString
+		if err := rows.Scan(&r.id, &r.date, &r.categories, &first, &r.publishers, &r.authors, &r.format, &r.hash); err != nil {
+			t.Fatal(err)
+		}
+		r.firstCategory = first.String
+		got = append(got, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d rows, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	var recent int
+	if err := db.QueryRow(`SELECT count(*) FROM products WHERE publication_date >= DATE '2024-06-01'`).Scan(&recent); err != nil {
+		t.Fatal(err)
+	}
+	if recent != 1 {
+		t.Errorf("products published since 2024-06-01 = %d, want 1", recent)
+	}
+}
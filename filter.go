@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// filter decides which fetched products make it into the output.
+type filter struct {
+	name string
+	keep func(Product) bool
+}
+
+// applyFilters keeps the products that pass every filter, in order, and logs
+// how many each filter dropped.
+func applyFilters(products []Product, filters []filter) []Product {
+	for _, f := range filters {
+		kept := products[:0:0]
+		for _, product := range products {
+			if f.keep(product) {
+				kept = append(kept, product)
+			}
+		}
+		log.Printf("%s: dropped %d of %d products", f.name, len(products)-len(kept), len(products))
+		products = kept
+	}
+	return products
+}
+
+// requireCover keeps products that have a cover image. When placeholder is
+// not nil, covers whose URL matches it count as missing.
+func requireCover(placeholder *regexp.Regexp) filter {
+	return filter{
+		name: "require-cover",
+		keep: func(product Product) bool {
+			if product.CoverImage == "" {
+				return false
+			}
+			return placeholder == nil || !placeholder.MatchString(product.CoverImage)
+		},
+	}
+}
+
+// typeFilter keeps products whose Type is one of types.
+func typeFilter(types []string) filter {
+	return filter{
+		name: "only-types",
+		keep: func(product Product) bool {
+			for _, t := range types {
+				if product.Type == t {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// publisherFilter keeps products with at least one publisher in publishers,
+// compared case-insensitively.
+func publisherFilter(publishers []string) filter {
+	allowed := make(map[string]bool, len(publishers))
+	for _, publisher := range publishers {
+		allowed[strings.ToLower(publisher)] = true
+	}
+	return filter{
+		name: "publishers-file",
+		keep: func(product Product) bool {
+			for _, publisher := range product.CustomAttributes.Publishers {
+				if allowed[strings.ToLower(strings.TrimSpace(publisher))] {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// idFilter keeps products whose ProductID is in ids, or, with exclude, those
+// whose ProductID is not.
+func idFilter(name string, ids []string, exclude bool) filter {
+	listed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		listed[id] = true
+	}
+	return filter{
+		name: name,
+		keep: func(product Product) bool {
+			return listed[product.ProductID] != exclude
+		},
+	}
+}
+
+// readListFile reads one entry per line from filename, trimming whitespace
+// and skipping blank lines and lines starting with #.
+func readListFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return entries, scanner.Err()
+}
+
+// capPerAuthor keeps at most n products per normalized author, preferring the
+// newest, and returns the kept products in their original order along with
+// how many were trimmed. A multi-author product counts toward each of its
+// authors and is dropped if any of them has already reached the cap.
+// Products without authors are always kept.
+func capPerAuthor(products []Product, n int) ([]Product, int) {
+	order := make([]int, len(products))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ta, okA := publicationTime(products[order[a]])
+		tb, okB := publicationTime(products[order[b]])
+		if okA != okB {
+			return okA
+		}
+		return ta.After(tb)
+	})
+
+	counts := make(map[string]int)
+	keep := make([]bool, len(products))
+	for _, i := range order {
+		keys := make(map[string]bool)
+		full := false
+		for _, author := range products[i].Authors {
+			key := authorKey(author)
+			if key == "" {
+				continue
+			}
+			keys[key] = true
+			if counts[key] >= n {
+				full = true
+			}
+		}
+		if full {
+			continue
+		}
+		keep[i] = true
+		for key := range keys {
+			counts[key]++
+		}
+	}
+
+	kept := make([]Product, 0, len(products))
+	for i, product := range products {
+		if keep[i] {
+			kept = append(kept, product)
+		}
+	}
+	return kept, len(products) - len(kept)
+}
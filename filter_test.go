@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestRequireCover(t *testing.T) {
+	placeholder := regexp.MustCompile(`/placeholder\.png$|no-cover`)
+	tests := []struct {
+		name        string
+		cover       string
+		placeholder *regexp.Regexp
+		want        bool
+	}{
+		{"empty URL", "", nil, false},
+		{"empty URL with placeholder", "", placeholder, false},
+		{"cover, nil placeholder", "https://example.com/9781.jpg", nil, true},
+		{"placeholder URL, nil placeholder", "https://example.com/placeholder.png", nil, true},
+		{"cover not matching", "https://example.com/9781.jpg", placeholder, true},
+		{"placeholder match", "https://example.com/img/placeholder.png", placeholder, false},
+		{"placeholder match mid-URL", "https://example.com/no-cover/9781.jpg", placeholder, false},
+	}
+	for _, test := range tests {
+		f := requireCover(test.placeholder)
+		if got := f.keep(Product{CoverImage: test.cover}); got != test.want {
+			t.Errorf("%s: keep(%q) = %t, want %t", test.name, test.cover, got, test.want)
+		}
+	}
+
+	products := []Product{{ProductID: "1", CoverImage: "https://example.com/1.jpg"}, {ProductID: "2"}}
+	kept := applyFilters(products, []filter{requireCover(nil)})
+	if len(kept) != 1 || kept[0].ProductID != "1" {
+		t.Errorf("applyFilters with requireCover kept %v, want only product 1", kept)
+	}
+}
+
+// datedProduct returns a product with the given ID, publication date and
+// authors.
+func datedProduct(id, date string, authors ...string) Product {
+	product := Product{ProductID: id, Authors: authors}
+	product.CustomAttributes.PublicationDate = date
+	return product
+}
+
+func TestCapPerAuthor(t *testing.T) {
+	products := []Product{
+		datedProduct("a-old", "2023-01-01", "Ann"),
+		datedProduct("ab", "2022-01-01", "ann ", "Bob"),
+		datedProduct("a-new", "2024-03-01", "Ann"),
+		datedProduct("c-undated", "", "Cy"),
+		datedProduct("c-dated", "2020-01-01", "Cy"),
+		datedProduct("anonymous", "2021-01-01"),
+		datedProduct("d-garbled", "not a date", "Di"),
+	}
+
+	kept, trimmed := capPerAuthor(products, 1)
+	var ids []string
+	for _, product := range kept {
+		ids = append(ids, product.ProductID)
+	}
+
+	// Ann's newest book fills her cap, so the co-authored "ab" is dropped
+	// even though Bob has nothing kept. The undated book by Cy sorts after
+	// the dated one and is trimmed; Di's only book is kept despite its bad
+	// date. The result keeps the input order.
+	want := []string{"a-new", "c-dated", "anonymous", "d-garbled"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("capPerAuthor kept %q, want %q", ids, want)
+	}
+	if trimmed != 3 {
+		t.Errorf("capPerAuthor trimmed %d, want 3", trimmed)
+	}
+
+	kept, trimmed = capPerAuthor(products, 2)
+	if len(kept) != len(products)-1 || trimmed != 1 {
+		t.Errorf("capPerAuthor with n=2 kept %d and trimmed %d, want %d and 1", len(kept), trimmed, len(products)-1)
+	}
+}
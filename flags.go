@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	query        = flag.String("query", "*", "search text; * matches the whole catalog")
+	queriesFile  = flag.String("queries-file", "", "run every search listed in this file, one per line, instead of -query")
+	types        = flag.String("types", "book", "comma-separated product types to fetch and merge, e.g. book,video")
+	languages    = flag.String("languages", "en", "comma-separated languages to fetch and merge, e.g. en,de")
+	pageSizeFlag = flag.Int("page-size", defaultPageSize, "products requested per page")
+	pagesFlag    = flag.Int("pages", defaultPageMax, "number of pages to fetch")
+
+	queryConcurrency = flag.Int("query-concurrency", 1, "number of searches fetched at the same time")
+	firstPageOnly    = flag.Bool("first-page-only", false, "fetch only page 0, the newest -page-size products, in a single request")
+
+	timezone   = flag.String("timezone", "", "IANA time zone for dates and file names, e.g. Europe/Berlin (default local)")
+	lineEnding = flag.String("line-ending", "lf", "line ending for text output: lf or crlf")
+
+	logFile = flag.String("log-file", "", "also append the log to this file; if it names a directory, write a timestamped log file per run there")
+
+	maxRuntime = flag.Duration("max-runtime", 0, "stop fetching, cover verification and cover downloads after this long and write what was completed, 0 for no limit")
+
+	rampDelay = flag.Duration("ramp-delay", 0, "wait this long between starting each of the first concurrent page fetches")
+
+	concurrencyPerHost = flag.Int("concurrency-per-host", maxConcurrent, "maximum concurrent requests to any single host")
+
+	otelEndpoint = flag.String("otel-endpoint", "", "OTLP/HTTP collector URL to send traces to; the OTEL_EXPORTER_OTLP_* variables also enable tracing")
+
+	retryCount     = flag.Int("retries", 3, "times to retry a request that fails or returns a 5xx status without its own -retry-policy")
+	retryBaseDelay = flag.Duration("retry-base-delay", time.Second, "delay before the first retry without its own -retry-policy; doubles on each further retry")
+	retryMaxDelay  = flag.Duration("retry-max-delay", 30*time.Second, "upper bound on the delay between retries")
+	retryOnEmpty   = flag.Int("retry-on-empty", 0, "times to refetch a page that comes back empty before the end of the results")
+	retryPolicies  = statusRetries{}
+	retryJitter    = flag.Bool("retry-jitter", true, "randomize each retry delay between half and all of its value")
+
+	formats          = flag.String("format", "csv,md", "comma-separated product list formats to write: csv, md, json, gsheet-tsv, duckdb (needs -tags duckdb)")
+	compact          = flag.Bool("compact", false, "write JSON as a single line without indentation")
+	omitEmpty        = flag.Bool("omit-empty", false, "leave empty strings and lists out of JSON records")
+	unescapeHTMLFlag = flag.Bool("unescape-html", true, "decode HTML entities like &amp; in titles, descriptions and names")
+
+	splitBy = flag.String("split-by", "", "write the product list as one oreilly-<value>-<date> file per language, category or type instead of one merged file")
+
+	longFormat = flag.Bool("long-format", false, "write the CSV with one row per product and category at -report-category-depth")
+	mdGroupBy  = flag.String("md-group-by", "", "split the Markdown list into sections; \"month\" groups by publication month")
+
+	requireCoverFlag = flag.Bool("require-cover", false, "drop products without a cover image")
+	coverPlaceholder = flag.String("cover-placeholder", "", "regular expression matching placeholder cover URLs that -require-cover treats as missing")
+
+	publishersFile = flag.String("publishers-file", "", "keep only products from the publishers listed in this file, one per line")
+
+	includeIDsFile = flag.String("include-ids-file", "", "keep only products whose ID is listed in this file, one per line")
+	excludeIDsFile = flag.String("exclude-ids-file", "", "drop products whose ID is listed in this file, one per line; wins over -include-ids-file")
+
+	onlyTypes  = flag.String("only-types", "", "keep only products of these comma-separated types after fetching")
+	formatType = flag.String("format-type", "", "keep only these comma-separated formats: ebook, video, other")
+
+	contentHashFlag = flag.Bool("content-hash", false, "add a SHA-256 of each product's metadata for change detection")
+
+	series             = flag.String("series", "", "keep only books in this series, e.g. \"Head First\"")
+	seriesPatternsFile = flag.String("series-patterns", "", "file of regular expressions, one per line, that recognize a series in a title; the first capture group names it")
+	seriesReport       = flag.Bool("series-report", false, "also write a Markdown list of books grouped by series")
+
+	maxPerAuthor = flag.Int("max-per-author", 0, "keep at most N of each author's newest books, 0 for no cap")
+
+	reportCategoryDepth = flag.String("report-category-depth", "1", "category level that -tag-cloud and -long-format aggregate by: 1 for the top level, 2, and so on, or leaf; shorter categories use their deepest level")
+
+	tagCloud      = flag.Bool("tag-cloud", false, "also write category weights for a tag cloud at -report-category-depth")
+	tagCloudScale = flag.Bool("tag-cloud-scale", false, "add a 1-10 scale column to the -tag-cloud output")
+
+	badge = flag.String("badge", "", "also write a Markdown badge snippet with the book count and update date to this file")
+
+	facets = flag.Bool("facets", false, "also write the facet counts from the first page to facets-<date>.json")
+
+	groupByType = flag.Bool("group-by-type", false, "also write a Markdown list with a section per product type")
+
+	coversFrom = flag.String("covers-from", "", "instead of fetching, download the covers of the products in this JSON output that are missing from -covers-dir")
+	coversDir  = flag.String("covers-dir", "covers", "directory -covers-from saves cover images in")
+
+	totalTrend = flag.String("total-trend", "", "append this run's date, reported total and unique products collected to this CSV; skipped if any search's first page fails")
+
+	bundle       = flag.String("bundle", "", "also package every output of the run into this .tar.gz with a manifest.json")
+	bundleCovers = flag.Bool("bundle-covers", false, "include the images in -covers-dir in the -bundle")
+
+	verifyCoversFlag = flag.Bool("verify-covers", false, "check every cover image URL with a HEAD request and list the broken ones; no list is written if the check is cut short")
+
+	authorLeaderboard = flag.Bool("author-leaderboard", false, "also write book counts per author as Markdown and CSV")
+	top               = flag.Int("top", 0, "limit -author-leaderboard to the top N authors, 0 for all")
+)
+
+func init() {
+	flag.Var(retryPolicies, "retry-policy", "per-status retry budget as STATUS=RETRIES:BASE-DELAY, e.g. 429=5:10s; repeatable. Unless -retries is given, 429, 503 and 500 have built-in budgets")
+}
+
+// envPrefix starts the environment variable that sets each flag, see
+// envVar.
+const envPrefix = "OREILLY_"
+
+// envVar returns the environment variable for the named flag, e.g.
+// OREILLY_PAGE_SIZE for -page-size.
+func envVar(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// setFlagsFromEnv sets every flag not given on the command line from its
+// environment variable, if that is set. Call it after flag.Parse so that
+// flags win over the environment.
+func setFlagsFromEnv() error {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] {
+			return
+		}
+		value, ok := os.LookupEnv(envVar(f.Name))
+		if !ok {
+			return
+		}
+		if setErr := flag.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("%s: %w", envVar(f.Name), setErr)
+		}
+	})
+	return err
+}
+
+// flagGiven reports whether the named flag was set on the command line or
+// from its environment variable.
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+	return given
+}
+
+// validateFlags checks flag values after flag.Parse.
+func validateFlags() error {
+	if *query == "" {
+		return fmt.Errorf("-query must not be empty; use * to match everything")
+	}
+	for _, t := range strings.Split(*types, ",") {
+		if t == "" {
+			return fmt.Errorf("-types must list product types, got %q", *types)
+		}
+	}
+	for _, language := range strings.Split(*languages, ",") {
+		if language == "" {
+			return fmt.Errorf("-languages must list language codes, got %q", *languages)
+		}
+	}
+	if *queryConcurrency < 1 {
+		return fmt.Errorf("-query-concurrency must be at least 1, got %d", *queryConcurrency)
+	}
+	if *pageSizeFlag < 1 {
+		return fmt.Errorf("-page-size must be at least 1, got %d", *pageSizeFlag)
+	}
+	if *pagesFlag < 1 {
+		return fmt.Errorf("-pages must be at least 1, got %d", *pagesFlag)
+	}
+	if _, err := time.LoadLocation(*timezone); err != nil {
+		return fmt.Errorf("-timezone: %w", err)
+	}
+	if *lineEnding != "lf" && *lineEnding != "crlf" {
+		return fmt.Errorf("-line-ending must be lf or crlf, got %q", *lineEnding)
+	}
+	if _, err := parseFormats(*formats); err != nil {
+		return fmt.Errorf("-format: %w", err)
+	}
+	if _, ok := splitters[*splitBy]; *splitBy != "" && !ok {
+		return fmt.Errorf("-split-by must be language, category or type, got %q", *splitBy)
+	}
+	if *mdGroupBy != "" && *mdGroupBy != "month" {
+		return fmt.Errorf("-md-group-by must be month, got %q", *mdGroupBy)
+	}
+	if *maxRuntime < 0 {
+		return fmt.Errorf("-max-runtime must not be negative, got %s", *maxRuntime)
+	}
+	if *rampDelay < 0 {
+		return fmt.Errorf("-ramp-delay must not be negative, got %s", *rampDelay)
+	}
+	if *concurrencyPerHost < 1 {
+		return fmt.Errorf("-concurrency-per-host must be at least 1, got %d", *concurrencyPerHost)
+	}
+	if *retryCount < 0 {
+		return fmt.Errorf("-retries must not be negative, got %d", *retryCount)
+	}
+	if *retryOnEmpty < 0 {
+		return fmt.Errorf("-retry-on-empty must not be negative, got %d", *retryOnEmpty)
+	}
+	if *retryBaseDelay <= 0 {
+		return fmt.Errorf("-retry-base-delay must be positive, got %s", *retryBaseDelay)
+	}
+	if *retryBaseDelay > *retryMaxDelay {
+		return fmt.Errorf("-retry-base-delay (%s) must not exceed -retry-max-delay (%s)", *retryBaseDelay, *retryMaxDelay)
+	}
+	if _, err := regexp.Compile(*coverPlaceholder); err != nil {
+		return fmt.Errorf("-cover-placeholder: %w", err)
+	}
+	if *formatType != "" {
+		for _, format := range strings.Split(*formatType, ",") {
+			if format != formatEbook && format != formatVideo && format != formatOther {
+				return fmt.Errorf("-format-type: unknown format %q", format)
+			}
+		}
+	}
+	if *maxPerAuthor < 0 {
+		return fmt.Errorf("-max-per-author must not be negative, got %d", *maxPerAuthor)
+	}
+	if _, err := parseCategoryDepth(*reportCategoryDepth); err != nil {
+		return fmt.Errorf("-report-category-depth: %w", err)
+	}
+	if *top < 0 {
+		return fmt.Errorf("-top must not be negative, got %d", *top)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import "strings"
+
+// Product formats reported by classifyFormat.
+const (
+	formatEbook = "ebook"
+	formatVideo = "video"
+	formatOther = "other"
+)
+
+// classifyFormat infers a product's format from its Type. The search API has
+// no finer-grained format field, and print editions are not listed, so
+// anything not recognizably an ebook or a video is formatOther.
+func classifyFormat(product Product) string {
+	switch strings.ToLower(product.Type) {
+	case "book", "ebook":
+		return formatEbook
+	case "video", "course":
+		return formatVideo
+	}
+	return formatOther
+}
+
+// formatTypeFilter keeps products whose classified format is in formats.
+func formatTypeFilter(formats []string) filter {
+	return filter{
+		name: "format-type",
+		keep: func(product Product) bool {
+			format := classifyFormat(product)
+			for _, f := range formats {
+				if f == format {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// hashedFields is the normalized form of a product that contentHash covers:
+// every field the API returns. Strings are trimmed; list order is kept since
+// the API orders authors and categories meaningfully.
+type hashedFields struct {
+	ProductID       string     `json:"product_id"`
+	URL             string     `json:"url"`
+	Language        string     `json:"language"`
+	Title           string     `json:"title"`
+	Type            string     `json:"type"`
+	Description     string     `json:"description"`
+	Categories      [][]string `json:"categories"`
+	CoverImage      string     `json:"cover_image"`
+	Publishers      []string   `json:"publishers"`
+	PublicationDate string     `json:"publication_date"`
+	Authors         []string   `json:"authors"`
+}
+
+// contentHash returns a hex SHA-256 of the product's metadata, stable across
+// runs, so changed records can be found by comparing hashes.
+func contentHash(product Product) string {
+	trimAll := func(values []string) []string {
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.TrimSpace(v)
+		}
+		return trimmed
+	}
+
+	fields := hashedFields{
+		ProductID:       strings.TrimSpace(product.ProductID),
+		URL:             strings.TrimSpace(product.URL),
+		Language:        strings.TrimSpace(product.Language),
+		Title:           strings.TrimSpace(product.Title),
+		Type:            strings.TrimSpace(product.Type),
+		Description:     strings.TrimSpace(product.Description),
+		CoverImage:      strings.TrimSpace(product.CoverImage),
+		Publishers:      trimAll(product.CustomAttributes.Publishers),
+		PublicationDate: strings.TrimSpace(product.CustomAttributes.PublicationDate),
+		Authors:         trimAll(product.Authors),
+	}
+	for _, category := range product.Categories {
+		fields.Categories = append(fields.Categories, trimAll(category))
+	}
+
+	// Marshaling a struct emits fields in declaration order, so the
+	// encoding is canonical.
+	data, _ := json.Marshal(fields)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,32 @@
+package main
+
+import "sync"
+
+// hostLimiter bounds the number of in-flight requests per host. Each host
+// gets its own semaphore so a slow server does not hold up requests to
+// another one.
+type hostLimiter struct {
+	limit int
+
+	mu   sync.Mutex
+	sems map[string]chan struct{}
+}
+
+func newHostLimiter(limit int) *hostLimiter {
+	return &hostLimiter{limit: limit, sems: make(map[string]chan struct{})}
+}
+
+// acquire blocks until a slot for host is free and returns a function that
+// releases it.
+func (l *hostLimiter) acquire(host string) func() {
+	l.mu.Lock()
+	sem, ok := l.sems[host]
+	if !ok {
+		sem = make(chan struct{}, l.limit)
+		l.sems[host] = sem
+	}
+	l.mu.Unlock()
+
+	sem <- struct{}{}
+	return func() { <-sem }
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// openLogFile tees the standard logger to path until the returned function is
+// called. If path is a directory, each run logs to a new timestamped file in
+// it; otherwise the log is appended to path.
+func openLogFile(path string) (func() error, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		name := fmt.Sprintf("oreilly-books-%s.log", time.Now().In(outputLoc).Format("2006-01-02T150405"))
+		path = filepath.Join(path, name)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	log.SetOutput(io.MultiWriter(os.Stderr, file))
+
+	return func() error {
+		log.SetOutput(os.Stderr)
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}, nil
+}
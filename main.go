@@ -1,15 +1,25 @@
 package main
 
 import (
-	"encoding/csv"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Response struct {
@@ -18,6 +28,11 @@ type Response struct {
 		Products []Product `json:"products"`
 		Total    int       `json:"total"`
 		Start    int       `json:"start"`
+
+		// Facets holds the per-field result counts the API returns
+		// alongside the products, keyed by field. Their shape is kept as
+		// sent.
+		Facets map[string]json.RawMessage `json:"facets"`
 	} `json:"data"`
 }
 
@@ -35,26 +50,225 @@ type Product struct {
 		PublicationDate string   `json:"publication_date"`
 	} `json:"custom_attributes"`
 	Authors []string `json:"authors"`
+
+	// Series is inferred from the title; see inferSeries.
+	Series string `json:"series,omitempty"`
+
+	// ContentHash is set by -content-hash; see contentHash.
+	ContentHash string `json:"content_hash,omitempty"`
 }
 
 const (
-	pageSize      = 100
-	pageMax       = 100
-	maxConcurrent = 5 // Adjust as needed
+	defaultPageSize = 100
+	defaultPageMax  = 100
+	maxPageSize     = 100 // Largest rows value the search API serves
+	maxConcurrent   = 5   // Adjust as needed
+
+	streamThreshold = 1 << 20 // Response bodies above this size are decoded incrementally
 )
 
+// hosts limits concurrent requests per host for every HTTP call site.
+var hosts = newHostLimiter(maxConcurrent)
+
+// retries is the retry policy applied to every HTTP call site.
+var retries retryPolicy
+
 func main() {
-	baseURL := fmt.Sprintf("https://www.oreilly.com/search/api/search/?q=*&type=book&order_by=published_at&rows=%d&language=en&page=", pageSize)
+	flag.Parse()
+	if err := setFlagsFromEnv(); err != nil {
+		log.Fatal(err)
+	}
+	if err := validateFlags(); err != nil {
+		log.Fatal(err)
+	}
+	if *timezone != "" {
+		outputLoc, _ = time.LoadLocation(*timezone)
+	}
+	categoryDepth, _ = parseCategoryDepth(*reportCategoryDepth)
+	if *lineEnding == "crlf" {
+		eol = "\r\n"
+	}
+	hosts = newHostLimiter(*concurrencyPerHost)
+	// An explicit -retries replaces the built-in per-status budgets, so
+	// -retries 0 turns retrying off unless -retry-policy asks for it.
+	byStatus := retryPolicies
+	if !flagGiven("retries") {
+		byStatus = defaultStatusRetries()
+		for status, policy := range retryPolicies {
+			byStatus[status] = policy
+		}
+	}
+	retries = retryPolicy{
+		Retries:   *retryCount,
+		BaseDelay: *retryBaseDelay,
+		MaxDelay:  *retryMaxDelay,
+		Jitter:    *retryJitter,
+		ByStatus:  byStatus,
+	}
+
+	os.Exit(execute())
+}
+
+// execute sets up logging, signal handling and tracing around run and
+// returns the process exit code. Everything it opens is closed before it
+// returns, including when the run is interrupted.
+func execute() int {
+	if *logFile != "" {
+		closeLog, err := openLogFile(*logFile)
+		if err != nil {
+			log.Printf("Error opening log file: %v", err)
+			return 1
+		}
+		defer func() {
+			if err := closeLog(); err != nil {
+				log.Printf("Error closing log file: %v", err)
+			}
+		}()
+	}
+
+	watchPauseSignals(dispatch)
+
+	// Interrupting stops the run early; what was fetched is still written.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *maxRuntime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *maxRuntime)
+		defer cancel()
+	}
+
+	shutdown, err := setupTracing(ctx, *otelEndpoint)
+	if err != nil {
+		log.Printf("Error setting up tracing: %v", err)
+		return 1
+	}
+
+	if *coversFrom != "" {
+		err = resumeCovers(ctx, *coversFrom, *coversDir)
+	} else {
+		err = run(ctx)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		log.Printf("Error flushing traces: %v", err)
+	}
+	if err != nil {
+		log.Printf("Error %v", err)
+		return 1
+	}
+
+	fmt.Println("Done.")
+	return 0
+}
+
+// run fetches the catalog and writes every requested output.
+func run(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "run")
+	defer span.End()
+
+	pageSize, pageMax := clampPageSize(*pageSizeFlag, *pagesFlag)
+	if *firstPageOnly {
+		pageMax = 1
+	}
+
+	texts := []string{*query}
+	if *queriesFile != "" {
+		var err error
+		if texts, err = readListFile(*queriesFile); err != nil {
+			return fmt.Errorf("reading queries file: %w", err)
+		}
+		if len(texts) == 0 {
+			return fmt.Errorf("queries file %s lists no queries", *queriesFile)
+		}
+	}
+	var queries []searchQuery
+	for _, text := range texts {
+		for _, t := range strings.Split(*types, ",") {
+			for _, language := range strings.Split(*languages, ",") {
+				queries = append(queries, searchQuery{Query: text, Type: t, Language: language})
+			}
+		}
+	}
+
+	patterns := defaultSeriesPatterns
+	if *seriesPatternsFile != "" {
+		var err error
+		if patterns, err = readListFile(*seriesPatternsFile); err != nil {
+			return fmt.Errorf("reading series patterns: %w", err)
+		}
+	}
+	seriesPatterns, err := compileSeriesPatterns(patterns)
+	if err != nil {
+		return fmt.Errorf("series patterns: %w", err)
+	}
+
+	// Build the filters up front so a bad list file fails before fetching.
+	var filters []filter
+	if *requireCoverFlag {
+		var placeholder *regexp.Regexp
+		if *coverPlaceholder != "" {
+			placeholder = regexp.MustCompile(*coverPlaceholder)
+		}
+		filters = append(filters, requireCover(placeholder))
+	}
+	if *onlyTypes != "" {
+		filters = append(filters, typeFilter(strings.Split(*onlyTypes, ",")))
+	}
+	if *publishersFile != "" {
+		publishers, err := readListFile(*publishersFile)
+		if err != nil {
+			return fmt.Errorf("reading publishers file: %w", err)
+		}
+		filters = append(filters, publisherFilter(publishers))
+	}
+	if *formatType != "" {
+		filters = append(filters, formatTypeFilter(strings.Split(*formatType, ",")))
+	}
+	if *series != "" {
+		filters = append(filters, seriesFilter(*series))
+	}
+	if *includeIDsFile != "" {
+		ids, err := readListFile(*includeIDsFile)
+		if err != nil {
+			return fmt.Errorf("reading include IDs: %w", err)
+		}
+		filters = append(filters, idFilter("include-ids", ids, false))
+	}
+	// Excluding after including means an ID on both lists is dropped.
+	if *excludeIDsFile != "" {
+		ids, err := readListFile(*excludeIDsFile)
+		if err != nil {
+			return fmt.Errorf("reading exclude IDs: %w", err)
+		}
+		filters = append(filters, idFilter("exclude-ids", ids, true))
+	}
 
 	var allProducts []Product
+	firstPages := make([]*Response, len(queries))
 	var wg sync.WaitGroup
 	productsChan := make(chan []Product, maxConcurrent)
 
-	// Fetch data concurrently
+	// Fetch data concurrently, running up to -query-concurrency queries at
+	// once, each with its own page fan-out.
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		fetchProducts(baseURL, pageMax, &wg, productsChan)
+		querySem := make(chan struct{}, *queryConcurrency)
+		var queryWG sync.WaitGroup
+		for i, q := range queries {
+			querySem <- struct{}{}
+			if ctx.Err() != nil {
+				break
+			}
+			queryWG.Add(1)
+			go func(i int, q searchQuery) {
+				defer queryWG.Done()
+				defer func() { <-querySem }()
+				firstPages[i] = fetchProducts(ctx, q, pageSize, pageMax, productsChan)
+			}(i, q)
+		}
+		// fetchProducts waits for its pages, so nothing sends after this.
+		queryWG.Wait()
 		close(productsChan)
 	}()
 
@@ -67,103 +281,395 @@ func main() {
 	}()
 
 	wg.Wait()
+	firstPage := firstPages[0]
+	if ctx.Err() != nil {
+		log.Printf("stopped while fetching (%v); writing the %d products fetched so far", ctx.Err(), len(allProducts))
+	}
 
-	fileDate := time.Now().Format("2006-01-02")
-	csvFilename := fmt.Sprintf("oreilly-book-list-%s.csv", fileDate)
-	markdownFilename := fmt.Sprintf("oreilly-book-list-%s.md", fileDate)
+	allProducts, dupes := dedupeProducts(allProducts)
+	log.Printf("dropped %d duplicate products", dupes)
+	collected := len(allProducts)
 
-	if err := writeCSV(csvFilename, allProducts); err != nil {
-		log.Fatalf("Error writing CSV: %v", err)
+	for i := range allProducts {
+		if *unescapeHTMLFlag {
+			unescapeHTML(&allProducts[i])
+		}
+		allProducts[i].Series = inferSeries(allProducts[i].Title, seriesPatterns)
 	}
 
-	if err := writeMarkdown(markdownFilename, allProducts); err != nil {
-		log.Fatalf("Error writing Markdown: %v", err)
+	allProducts = applyFilters(allProducts, filters)
+
+	if *maxPerAuthor > 0 {
+		var trimmed int
+		allProducts, trimmed = capPerAuthor(allProducts, *maxPerAuthor)
+		log.Printf("max-per-author: trimmed %d products", trimmed)
 	}
 
-	fmt.Println("Done.")
+	if *contentHashFlag {
+		for i := range allProducts {
+			allProducts[i].ContentHash = contentHash(allProducts[i])
+		}
+	}
+
+	// written collects every file the run produces, for -bundle. A run
+	// never writes the same file twice, so one output cannot silently
+	// replace another.
+	var written []string
+	produced := make(map[string]bool)
+	write := func(what, filename string, fn func(filename string) error) error {
+		if produced[filepath.Clean(filename)] {
+			return fmt.Errorf("writing %s: %s was already written by this run", what, filename)
+		}
+		produced[filepath.Clean(filename)] = true
+		if err := traced(ctx, "write "+what, func() error { return fn(filename) }); err != nil {
+			return fmt.Errorf("writing %s: %w", what, err)
+		}
+		written = append(written, filename)
+		return nil
+	}
+
+	fileDate := time.Now().In(outputLoc).Format("2006-01-02")
+	names, _ := parseFormats(*formats) // checked by validateFlags
+	if *splitBy == "" {
+		for _, name := range names {
+			format := outputFormats[name]
+			filename := fmt.Sprintf("oreilly-book-list-%s.%s", fileDate, format.ext)
+			if err := write(format.label, filename, func(filename string) error { return format.write(filename, allProducts) }); err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, group := range splitProducts(allProducts, splitters[*splitBy]) {
+			var files []string
+			for _, name := range names {
+				format := outputFormats[name]
+				filename := fmt.Sprintf("oreilly-%s-%s.%s", group.Key, fileDate, format.ext)
+				if err := write(format.label, filename, func(filename string) error { return format.write(filename, group.Products) }); err != nil {
+					return err
+				}
+				files = append(files, filename)
+			}
+			log.Printf("split by %s: %d products in %s", *splitBy, len(group.Products), strings.Join(files, ", "))
+		}
+	}
+
+	if *tagCloud {
+		weights := countCategories(allProducts, categoryDepth)
+		if err := write("tag cloud", fmt.Sprintf("oreilly-tag-cloud-%s.csv", fileDate), func(filename string) error {
+			return writeTagCloud(filename, weights, *tagCloudScale)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if *authorLeaderboard {
+		leaderboard := countAuthors(allProducts, *top)
+		if err := write("author leaderboard CSV", fmt.Sprintf("oreilly-author-leaderboard-%s.csv", fileDate), func(filename string) error {
+			return writeAuthorLeaderboardCSV(filename, leaderboard)
+		}); err != nil {
+			return err
+		}
+		if err := write("author leaderboard Markdown", fmt.Sprintf("oreilly-author-leaderboard-%s.md", fileDate), func(filename string) error {
+			return writeAuthorLeaderboardMarkdown(filename, leaderboard)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if *groupByType {
+		if err := write("type groups", fmt.Sprintf("oreilly-by-type-%s.md", fileDate), func(filename string) error {
+			return writeTypeGroups(filename, allProducts)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if *badge != "" {
+		if err := write("badge", *badge, func(filename string) error {
+			return writeBadge(filename, len(allProducts), fileDate)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if *seriesReport {
+		if err := write("series report", fmt.Sprintf("oreilly-series-%s.md", fileDate), func(filename string) error {
+			return writeSeriesReport(filename, allProducts)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if *facets {
+		if firstPage == nil {
+			log.Printf("facets: the first page was not fetched, skipping")
+		} else if len(firstPage.Data.Facets) == 0 {
+			log.Printf("facets: the first page returned none, skipping")
+		} else if err := write("facets", fmt.Sprintf("facets-%s.json", fileDate), func(filename string) error {
+			return writeFacets(filename, firstPage.Data.Facets)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if *verifyCoversFlag {
+		broken, checked := verifyCovers(ctx, allProducts)
+		// A partial list would read as "nothing else is broken", so only a
+		// complete check is written.
+		if checked < len(allProducts) {
+			log.Printf("Error verifying covers: stopped (%v) after %d of %d; not writing the broken covers list (%d broken so far)", ctx.Err(), checked, len(allProducts), len(broken))
+		} else if err := write("broken covers", fmt.Sprintf("oreilly-broken-covers-%s.csv", fileDate), func(filename string) error {
+			return writeBrokenCovers(filename, broken)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if *totalTrend != "" {
+		// The total comes from each query's first page. Without all of
+		// them the sum would undercount, and the trend would show the
+		// catalog shrinking.
+		total, missing := 0, 0
+		for _, page := range firstPages {
+			if page == nil {
+				missing++
+				continue
+			}
+			total += page.Data.Total
+		}
+		if missing > 0 {
+			log.Printf("Error writing total trend: the first page of %d of %d queries was not fetched; skipping today's row", missing, len(firstPages))
+		} else if err := traced(ctx, "write total trend", func() error {
+			return appendTotalTrend(*totalTrend, fileDate, total, collected)
+		}); err != nil {
+			return fmt.Errorf("writing total trend: %w", err)
+		}
+	}
+
+	if *bundle != "" {
+		var coverDir string
+		if *bundleCovers {
+			coverDir = *coversDir
+		}
+		if err := traced(ctx, "write bundle", func() error { return writeBundle(*bundle, written, coverDir) }); err != nil {
+			return fmt.Errorf("writing bundle: %w", err)
+		}
+		log.Printf("bundled %d outputs into %s", len(written), *bundle)
+	}
+
+	return nil
 }
 
-func fetchProducts(baseURL string, pageMax int, wg *sync.WaitGroup, productsChan chan<- []Product) {
+// resumeCovers downloads the covers of the products in a previous JSON output
+// that are not yet in dir, without fetching the catalog.
+func resumeCovers(ctx context.Context, jsonFile, dir string) error {
+	ctx, span := tracer.Start(ctx, "resume covers")
+	defer span.End()
+
+	products, err := readProductsJSON(jsonFile)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", jsonFile, err)
+	}
+
+	counts, err := downloadCovers(ctx, products, dir)
+	if err != nil {
+		return fmt.Errorf("downloading covers: %w", err)
+	}
+	if ctx.Err() != nil {
+		log.Printf("stopped while downloading covers (%v)", ctx.Err())
+	}
+	log.Printf("covers: %d downloaded, %d already present, %d without a cover, %d failed", counts.Downloaded, counts.Present, counts.NoCover, counts.Failed)
+	return nil
+}
+
+// clampPageSize caps size at maxPageSize, the API's limit, and raises pages so
+// the same number of products is still requested.
+func clampPageSize(size, pages int) (int, int) {
+	if size <= maxPageSize {
+		return size, pages
+	}
+	want := size * pages
+	clampedPages := (want + maxPageSize - 1) / maxPageSize
+	log.Printf("page size %d exceeds the API maximum of %d; fetching %d pages of %d instead", size, maxPageSize, clampedPages, maxPageSize)
+	return maxPageSize, clampedPages
+}
+
+// fetchProducts fetches pages 0 to pageMax-1 of q concurrently and sends their
+// products to productsChan. Products the API leaves without a type or
+// language get q's.
+// It returns page 0's response, minus its products, or nil if page 0 failed
+// or was never fetched. It returns only after every page goroutine has
+// finished, so the caller may close productsChan once all its fetchProducts
+// calls have returned.
+func fetchProducts(ctx context.Context, q searchQuery, pageSize, pageMax int, productsChan chan<- []Product) *Response {
 	sem := make(chan struct{}, maxConcurrent) // Semaphore to limit concurrency
+	var (
+		pages     sync.WaitGroup
+		firstPage *Response
+	)
+
+	// Stagger the first batch of workers instead of starting them at once.
+	ramp := min(maxConcurrent, pageMax)
+	if *rampDelay > 0 && ramp > 1 {
+		log.Printf("ramping up %d workers, one every %s", ramp, *rampDelay)
+	}
 
 	for page := 0; page < pageMax; page++ {
+		if *rampDelay > 0 && page > 0 && page < ramp {
+			select {
+			case <-time.After(*rampDelay):
+			case <-ctx.Done():
+			}
+		}
+		dispatch.wait(ctx)
 		sem <- struct{}{} // Acquire a token
-		wg.Add(1)
+		if ctx.Err() != nil {
+			break
+		}
+		pages.Add(1)
 
 		go func(page int) {
-			defer wg.Done()
+			defer pages.Done()
 			defer func() { <-sem }() // Release the token
 
-			url := fmt.Sprintf("%s%d", baseURL, page)
-			response, err := fetchData(url)
+			url := q.pageURL(pageSize, page)
+			ctx, span := tracer.Start(ctx, "fetch page", trace.WithAttributes(
+				attribute.Int("page", page),
+				attribute.String("url", url),
+			))
+			defer span.End()
+
+			var (
+				response Response
+				err      error
+				count    int
+			)
+			for empty := 0; ; empty++ {
+				response, err = fetchData(ctx, url, func(products []Product) {
+					count += len(products)
+					for i := range products {
+						if products[i].Type == "" {
+							products[i].Type = q.Type
+						}
+						if products[i].Language == "" {
+							products[i].Language = q.Language
+						}
+					}
+					// Send the products to the channel
+					productsChan <- products
+				})
+				// An empty page before the end of the results is
+				// usually a backend glitch; try it again.
+				if err != nil || count > 0 || empty >= *retryOnEmpty || response.Data.Total <= page*pageSize {
+					break
+				}
+				wait := retries.delay(empty)
+				log.Printf("page %d empty although the search has %d results; retrying in %s (%d/%d)", page, response.Data.Total, wait, empty+1, *retryOnEmpty)
+				span.AddEvent("empty page retry")
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+				}
+			}
 			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
 				log.Printf("Error fetching data from page %d: %v", page, err)
 				return
 			}
 
-			log.Printf("page: %d, %s, %d", page, url, len(response.Data.Products))
-
-			// Send the products to the channel
-			productsChan <- response.Data.Products
+			log.Printf("page: %d, %s, %d", page, url, count)
+			if page == 0 {
+				firstPage = &response
+			}
 		}(page)
 	}
+	pages.Wait()
+	return firstPage
 }
 
-func fetchData(apiURL string) (Response, error) {
-	req, err := http.NewRequest("GET", apiURL, nil)
+// newRequest builds a request carrying the headers O'Reilly expects from a
+// browser.
+func newRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
-		return Response{}, err
+		return nil, err
 	}
 
 	req.Header.Add("referer", "https://www.oreilly.com/")
 	req.Header.Add("user-agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:133.0) Gecko/20100101 Firefox/133.0")
+	return req, nil
+}
+
+// fetchData fetches one search page and passes its products to emit as they
+// are decoded; they are not kept in the returned Response. Large or chunked
+// bodies are decoded incrementally so products flow before the page has been
+// read in full.
+func fetchData(ctx context.Context, apiURL string, emit func([]Product)) (Response, error) {
+	req, err := newRequest(ctx, "GET", apiURL)
+	if err != nil {
+		return Response{}, err
+	}
+
+	release := hosts.acquire(req.URL.Host)
+	defer release()
 
 	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doWithRetry(client, req)
 	if err != nil {
 		return Response{}, err
 	}
 	defer resp.Body.Close()
 
+	if resp.ContentLength < 0 || resp.ContentLength > streamThreshold {
+		return decodeResponseStream(resp.Body, func(product Product) {
+			emit([]Product{product})
+		})
+	}
+
 	var response Response
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return Response{}, err
 	}
 
+	emit(response.Data.Products)
+	response.Data.Products = nil
 	return response, nil
 }
 
-func writeCSV(filename string, products []Product) error {
+// writeCSV writes product data to a CSV file. With longFormat each product
+// gets one row per category at categoryDepth instead of a joined Categories
+// column.
+func writeCSV(filename string, products []Product, longFormat bool) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	writer := csv.NewWriter(file)
+	writer := newCSVWriter(file)
 	defer writer.Flush()
 
 	// Write CSV header
-	header := []string{"Title", "Publication Date", "URL", "Type", "Language", "Categories", "Cover Image", "Publishers", "Authors"}
+	header := csvHeader()
+	if longFormat {
+		header[5] = "Category"
+	}
 	if err := writer.Write(header); err != nil {
 		return err
 	}
 
 	// Write product data to CSV
+	if longFormat {
+		for _, pc := range expandByCategory(products, categoryDepth) {
+			if err := writer.Write(csvRow(pc.Product, pc.Category)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 	for _, product := range products {
-		categories := formatCategories(product.Categories)
-		row := []string{
-			product.Title,
-			product.CustomAttributes.PublicationDate,
-			product.URL,
-			product.Type,
-			product.Language,
-			categories,
-			product.CoverImage,
-			fmt.Sprintf("%v", product.CustomAttributes.Publishers),
-			fmt.Sprintf("%v", product.Authors),
-		}
-		if err := writer.Write(row); err != nil {
+		if err := writer.Write(csvRow(product, formatCategories(product.Categories))); err != nil {
 			return err
 		}
 	}
@@ -171,17 +677,98 @@ func writeCSV(filename string, products []Product) error {
 	return nil
 }
 
-// writeMarkdown writes product data to a Markdown file
-func writeMarkdown(filename string, products []Product) error {
+// csvHeader returns the column names matching csvRow.
+func csvHeader() []string {
+	header := []string{"Title", "Publication Date", "URL", "Type", "Language", "Categories", "Cover Image", "Publishers", "Authors", "Format"}
+	if *contentHashFlag {
+		header = append(header, "Content Hash")
+	}
+	return header
+}
+
+// csvRow returns the CSV fields for product with the given categories cell.
+func csvRow(product Product, categories string) []string {
+	row := []string{
+		product.Title,
+		displayDate(product),
+		product.URL,
+		product.Type,
+		product.Language,
+		categories,
+		product.CoverImage,
+		fmt.Sprintf("%v", product.CustomAttributes.Publishers),
+		fmt.Sprintf("%v", product.Authors),
+		classifyFormat(product),
+	}
+	if *contentHashFlag {
+		row = append(row, product.ContentHash)
+	}
+	return row
+}
+
+// productCategory pairs a product with one of its categories.
+type productCategory struct {
+	Product  Product
+	Category string
+}
+
+// expandByCategory returns one pair per distinct category of each product at
+// the given depth, as picked by categoryAt, in order. A product without
+// categories yields a single pair with an empty category.
+func expandByCategory(products []Product, depth int) []productCategory {
+	var pairs []productCategory
+	for _, product := range products {
+		seen := make(map[string]bool)
+		for _, category := range product.Categories {
+			name := categoryAt(category, depth)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			pairs = append(pairs, productCategory{Product: product, Category: name})
+		}
+		if len(seen) == 0 {
+			pairs = append(pairs, productCategory{Product: product})
+		}
+	}
+	return pairs
+}
+
+// writeMarkdown writes product data to a Markdown file. With groupBy "month"
+// the products are split into one section per publication month.
+func writeMarkdown(filename string, products []Product, groupBy string) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
+	if groupBy != "month" {
+		return writeMarkdownTable(file, products)
+	}
+
+	for i, group := range groupByMonth(products) {
+		if i > 0 {
+			if _, err := io.WriteString(file, eol); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(file, "## "+group.Title+eol+eol); err != nil {
+			return err
+		}
+		if err := writeMarkdownTable(file, group.Products); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeMarkdownTable writes products as a Markdown table.
+func writeMarkdownTable(w io.Writer, products []Product) error {
 	// Write Markdown header
 	header := []string{"Title", "Publication Date", "Categories"}
-	_, err = file.WriteString("| " + strings.Join(header, " | ") + " |\n")
+	_, err := io.WriteString(w, "| "+strings.Join(header, " | ")+" |"+eol)
 	if err != nil {
 		return err
 	}
@@ -191,7 +778,7 @@ func writeMarkdown(filename string, products []Product) error {
 	for i := range separator {
 		separator[i] = "---"
 	}
-	_, err = file.WriteString("| " + strings.Join(separator, " | ") + " |\n")
+	_, err = io.WriteString(w, "| "+strings.Join(separator, " | ")+" |"+eol)
 	if err != nil {
 		return err
 	}
@@ -200,8 +787,8 @@ func writeMarkdown(filename string, products []Product) error {
 	for _, product := range products {
 		categories := formatCategories(product.Categories)
 
-		item := fmt.Sprintf("| [%s](%s) | %s | %s |\n", product.Title, product.URL, product.CustomAttributes.PublicationDate, categories)
-		_, err := file.WriteString(item)
+		item := fmt.Sprintf("| [%s](%s) | %s | %s |%s", product.Title, product.URL, displayDate(product), categories, eol)
+		_, err := io.WriteString(w, item)
 		if err != nil {
 			return err
 		}
@@ -210,15 +797,13 @@ func writeMarkdown(filename string, products []Product) error {
 	return nil
 }
 
+// formatCategories joins the top-level name of each category with " > ".
 func formatCategories(categories [][]string) string {
-	var formatted string
+	var names []string
 	for _, category := range categories {
 		if len(category) > 0 {
-			formatted += fmt.Sprintf("%s > ", category[0])
+			names = append(names, category[0])
 		}
 	}
-	if len(formatted) > 0 {
-		formatted = formatted[:len(formatted)-3] // Remove trailing " > "
-	}
-	return formatted
+	return strings.Join(names, " > ")
 }
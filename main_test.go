@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestFetchProductsClosesAfterPages checks that fetchProducts returns only
+// after every page goroutine has sent its products, so closing the channel
+// right after it returns cannot panic. Run it with -race.
+func TestFetchProductsClosesAfterPages(t *testing.T) {
+	const pageMax = 300
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		page := r.URL.Query().Get("page")
+		fmt.Fprintf(w, `{"data":{"products":[{"product_id":%q,"title":"Book %s"}],"total":%d}}`, page, page, pageMax)
+	}))
+	defer srv.Close()
+
+	oldURL := searchURL
+	searchURL = srv.URL + "/"
+	defer func() { searchURL = oldURL }()
+
+	productsChan := make(chan []Product, maxConcurrent)
+	seen := make(map[string]bool)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for products := range productsChan {
+			for _, product := range products {
+				seen[product.ProductID] = true
+			}
+		}
+	}()
+
+	firstPage := fetchProducts(context.Background(), searchQuery{Query: "*", Type: "book", Language: "en"}, 1, pageMax, productsChan)
+	close(productsChan)
+	<-done
+
+	if len(seen) != pageMax {
+		t.Errorf("got products from %d pages, want %d", len(seen), pageMax)
+	}
+	for page := 0; page < pageMax; page++ {
+		if !seen[strconv.Itoa(page)] {
+			t.Errorf("missing the product from page %d", page)
+			break
+		}
+	}
+	if firstPage == nil {
+		t.Fatal("no first page returned")
+	}
+	if firstPage.Data.Total != pageMax {
+		t.Errorf("first page total = %d, want %d", firstPage.Data.Total, pageMax)
+	}
+}
+
+func TestFormatCategories(t *testing.T) {
+	tests := []struct {
+		name       string
+		categories [][]string
+		want       string
+	}{
+		{"none", nil, ""},
+		{"one", [][]string{{"Programming", "Go"}}, "Programming"},
+		{"several", [][]string{{"Programming"}, {"Data"}}, "Programming > Data"},
+		{"name with separator", [][]string{{"A > B"}}, "A > B"},
+		{"name ending in separator", [][]string{{"A > "}, {"B"}}, "A >  > B"},
+		{"empty inner slices", [][]string{{}, {"Data"}, {}}, "Data"},
+		{"only empty inner slices", [][]string{{}, {}}, ""},
+		{"multibyte", [][]string{{"プログラミング"}, {"Données"}}, "プログラミング > Données"},
+		{"multibyte at the end", [][]string{{"Go"}, {"日本語"}}, "Go > 日本語"},
+	}
+	for _, test := range tests {
+		if got := formatCategories(test.categories); got != test.want {
+			t.Errorf("%s: formatCategories(%q) = %q, want %q", test.name, test.categories, got, test.want)
+		}
+	}
+}
+
+func TestExpandByCategory(t *testing.T) {
+	products := []Product{
+		{ProductID: "1", Categories: [][]string{{"Programming", "Go"}, {"Programming", "Rust"}, {"Data"}}},
+		{ProductID: "2"},
+		{ProductID: "3", Categories: [][]string{{}}},
+	}
+	type pair struct{ id, category string }
+	expand := func(depth int) []pair {
+		var got []pair
+		for _, pc := range expandByCategory(products, depth) {
+			got = append(got, pair{pc.Product.ProductID, pc.Category})
+		}
+		return got
+	}
+
+	tests := []struct {
+		name  string
+		depth int
+		want  []pair
+	}{
+		{"top level dedupes repeated names", 1, []pair{
+			{"1", "Programming"}, {"1", "Data"}, {"2", ""}, {"3", ""},
+		}},
+		{"second level falls back to the deepest", 2, []pair{
+			{"1", "Go"}, {"1", "Rust"}, {"1", "Data"}, {"2", ""}, {"3", ""},
+		}},
+		{"leaf", leafDepth, []pair{
+			{"1", "Go"}, {"1", "Rust"}, {"1", "Data"}, {"2", ""}, {"3", ""},
+		}},
+	}
+	for _, test := range tests {
+		if got := expand(test.depth); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expandByCategory = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// serveSearch points searchURL at a local server running handler for the
+// rest of the test.
+func serveSearch(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	oldURL := searchURL
+	searchURL = srv.URL + "/"
+	t.Cleanup(func() { searchURL = oldURL })
+}
+
+// setFlags sets command-line flags for the rest of the test.
+func setFlags(t *testing.T, values map[string]string) {
+	t.Helper()
+	for name, value := range values {
+		f := flag.Lookup(name)
+		old := f.Value.String()
+		if err := f.Value.Set(value); err != nil {
+			t.Fatalf("-%s=%s: %v", name, value, err)
+		}
+		t.Cleanup(func() { f.Value.Set(old) })
+	}
+}
+
+// inTempDir runs the rest of the test in a new temporary directory.
+func inTempDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	old, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(old) })
+	return dir
+}
+
+func TestRunSplitKeepsReports(t *testing.T) {
+	serveSearch(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"products":[
+			{"product_id":"1","title":"Clouds","type":"book","categories":[["Tag Cloud"]]},
+			{"product_id":"2","title":"Series","type":"book","categories":[["Series"],["Go"]]}
+		],"total":2}}`)
+	})
+	setFlags(t, map[string]string{
+		"pages": "1", "format": "csv", "split-by": "category",
+		"tag-cloud": "true", "series-report": "true", "retries": "0",
+	})
+	dir := inTempDir(t)
+
+	if err := run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	date := time.Now().In(outputLoc).Format("2006-01-02")
+	for _, name := range []string{"tag-cloud-products", "series-products", "go", "tag-cloud"} {
+		filename := filepath.Join(dir, fmt.Sprintf("oreilly-%s-%s.csv", name, date))
+		if _, err := os.Stat(filename); err != nil {
+			t.Errorf("missing output: %v", err)
+		}
+	}
+	cloud, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("oreilly-tag-cloud-%s.csv", date)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(cloud), "category,weight") {
+		t.Errorf("tag cloud was overwritten: %q", cloud)
+	}
+}
+
+func TestRunRefusesToOverwriteOwnOutput(t *testing.T) {
+	serveSearch(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"products":[{"product_id":"1","title":"Go","type":"book"}],"total":1}}`)
+	})
+	date := time.Now().In(outputLoc).Format("2006-01-02")
+	list := fmt.Sprintf("oreilly-book-list-%s.csv", date)
+	setFlags(t, map[string]string{"pages": "1", "format": "csv", "badge": list, "retries": "0"})
+	inTempDir(t)
+
+	err := run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "already written") {
+		t.Fatalf("run with -badge naming the product list = %v, want an already written error", err)
+	}
+	data, err := os.ReadFile(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "Title,") {
+		t.Errorf("product list was overwritten: %q", data)
+	}
+}
+
+func TestRunMergesConcurrentQueries(t *testing.T) {
+	serveSearch(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		q, typ := r.URL.Query().Get("q"), r.URL.Query().Get("type")
+		fmt.Fprintf(w, `{"data":{"products":[
+			{"product_id":"shared-1","type":%[2]q},
+			{"product_id":"shared-2"},
+			{"product_id":"only-%[1]s","type":%[2]q}
+		],"total":3}}`, q, typ)
+	})
+	dir := inTempDir(t)
+	queries := filepath.Join(dir, "queries.txt")
+	if err := os.WriteFile(queries, []byte("go\nrust\n# comment\npython\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	setFlags(t, map[string]string{
+		"queries-file": queries, "types": "book,video", "query-concurrency": "3",
+		"pages": "1", "format": "json", "retries": "0",
+	})
+
+	if err := run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	date := time.Now().In(outputLoc).Format("2006-01-02")
+	products, err := readProductsJSON(fmt.Sprintf("oreilly-book-list-%s.json", date))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]int)
+	for _, product := range products {
+		got[product.Type+"/"+product.ProductID]++
+	}
+	want := make(map[string]int)
+	for _, typ := range []string{"book", "video"} {
+		for _, id := range []string{"shared-1", "shared-2", "only-go", "only-rust", "only-python"} {
+			want[typ+"/"+id] = 1
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged products = %v, want %v", got, want)
+	}
+}
+
+func TestRunSkipsIncompleteCoverCheck(t *testing.T) {
+	covers := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer covers.Close()
+	serveSearch(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"data":{"products":[{"product_id":"1","cover_image":%q}],"total":1}}`, covers.URL+"/1.jpg")
+	})
+	setFlags(t, map[string]string{"pages": "1", "format": "csv", "verify-covers": "true", "retries": "0"})
+	inTempDir(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	date := time.Now().In(outputLoc).Format("2006-01-02")
+	if _, err := os.Stat(fmt.Sprintf("oreilly-book-list-%s.csv", date)); err != nil {
+		t.Errorf("product list not written: %v", err)
+	}
+	if _, err := os.Stat(fmt.Sprintf("oreilly-broken-covers-%s.csv", date)); err == nil {
+		t.Error("broken covers list written although the check was cut short")
+	}
+}
+
+func TestRunTotalTrendNeedsEveryFirstPage(t *testing.T) {
+	var failVideo atomic.Bool
+	serveSearch(t, func(w http.ResponseWriter, r *http.Request) {
+		if failVideo.Load() && r.URL.Query().Get("type") == "video" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"products":[{"product_id":"1"}],"total":40}}`)
+	})
+	dir := inTempDir(t)
+	trend := filepath.Join(dir, "trend.csv")
+	setFlags(t, map[string]string{"types": "book,video", "pages": "1", "format": "csv", "total-trend": trend, "retries": "0"})
+
+	failVideo.Store(true)
+	if err := run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(trend); err == nil {
+		t.Fatal("trend row written although a query's first page failed")
+	}
+
+	failVideo.Store(false)
+	if err := run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(trend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Now().In(outputLoc).Format("2006-01-02")
+	if want := "date,total,unique_collected\n" + date + ",80,2\n"; string(data) != want {
+		t.Errorf("trend file = %q, want %q", data, want)
+	}
+}
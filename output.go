@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// outputFormat is a way of writing the main product list.
+type outputFormat struct {
+	label string // for error messages
+	ext   string
+	write func(filename string, products []Product) error
+}
+
+// outputFormats are the product list writers selectable with -format.
+var outputFormats = map[string]outputFormat{
+	"csv": {"CSV", "csv", func(filename string, products []Product) error {
+		return writeCSV(filename, products, *longFormat)
+	}},
+	"md": {"Markdown", "md", func(filename string, products []Product) error {
+		return writeMarkdown(filename, products, *mdGroupBy)
+	}},
+	"json": {"JSON", "json", func(filename string, products []Product) error {
+		return writeJSON(filename, products, *omitEmpty, *compact)
+	}},
+	"gsheet-tsv": {"Google Sheets TSV", "tsv", writeSheetsTSV},
+}
+
+// eol terminates every line of text output. It is "\r\n" with
+// -line-ending crlf.
+var eol = "\n"
+
+// newCSVWriter returns a CSV writer that ends records with eol.
+func newCSVWriter(w io.Writer) *csv.Writer {
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = eol == "\r\n"
+	return writer
+}
+
+// parseFormats returns the distinct format names in a comma-separated -format
+// value. It fails if a name is not in outputFormats or if none are given,
+// listing the formats that are available.
+func parseFormats(value string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if _, ok := outputFormats[name]; !ok {
+			return nil, fmt.Errorf("unknown format %q (available: %s)", name, availableFormats())
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no format selected (available: %s)", availableFormats())
+	}
+	return names, nil
+}
+
+// availableFormats lists the outputFormats names, sorted.
+func availableFormats() string {
+	names := make([]string, 0, len(outputFormats))
+	for name := range outputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// productOmitEmpty mirrors Product with omitempty on every field. Converting
+// a Product to it changes only how it marshals, and the output still decodes
+// into a Product.
+type productOmitEmpty struct {
+	ProductID        string     `json:"product_id,omitempty"`
+	URL              string     `json:"url,omitempty"`
+	Language         string     `json:"language,omitempty"`
+	Title            string     `json:"title,omitempty"`
+	Type             string     `json:"type,omitempty"`
+	Description      string     `json:"description,omitempty"`
+	Categories       [][]string `json:"categories,omitempty"`
+	CoverImage       string     `json:"cover_image,omitempty"`
+	CustomAttributes struct {
+		Publishers      []string `json:"publishers,omitempty"`
+		PublicationDate string   `json:"publication_date,omitempty"`
+	} `json:"custom_attributes"`
+	Authors []string `json:"authors,omitempty"`
+
+	Series string `json:"series,omitempty"`
+
+	ContentHash string `json:"content_hash,omitempty"`
+}
+
+// writeJSON writes products as a JSON array, one record at a time so the
+// whole array is never held in memory. It is indented unless compact, which
+// puts the array on a single line. With omitEmpty, empty strings and lists
+// are left out of each record.
+func writeJSON(filename string, products []Product, omitEmpty, compact bool) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	open, sep, end := "["+eol+"  ", ","+eol+"  ", eol+"]"+eol
+	if compact {
+		open, sep, end = "[", ",", "]"+eol
+	}
+	if len(products) == 0 {
+		open, end = "[", "]"+eol
+	}
+
+	w.WriteString(open)
+	for i, product := range products {
+		var v any = product
+		if omitEmpty {
+			v = productOmitEmpty(product)
+		}
+
+		var data []byte
+		if compact {
+			data, err = json.Marshal(v)
+		} else {
+			data, err = json.MarshalIndent(v, "  ", "  ")
+			data = jsonLineEndings(data)
+		}
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			w.WriteString(sep)
+		}
+		w.Write(data)
+	}
+	w.WriteString(end)
+
+	return w.Flush()
+}
+
+// jsonLineEndings replaces the newlines json.MarshalIndent puts between
+// values with eol. Newlines inside strings are escaped as \n, so no value
+// changes.
+func jsonLineEndings(data []byte) []byte {
+	if eol == "\n" {
+		return data
+	}
+	return bytes.ReplaceAll(data, []byte("\n"), []byte(eol))
+}
+
+// tsvCleaner turns the characters that would break a TSV row into spaces.
+var tsvCleaner = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+
+// writeSheetsTSV writes products as tab-separated values for pasting into
+// Google Sheets. Fields are not quoted; embedded tabs and line breaks become
+// spaces so every product stays on one row with one value per cell.
+func writeSheetsTSV(filename string, products []Product) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := io.WriteString(file, strings.Join(csvHeader(), "\t")+eol); err != nil {
+		return err
+	}
+	for _, product := range products {
+		row := csvRow(product, formatCategories(product.Categories))
+		for i, field := range row {
+			row[i] = tsvCleaner.Replace(field)
+		}
+		if _, err := io.WriteString(file, strings.Join(row, "\t")+eol); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// setEOL sets eol for the rest of the test.
+func setEOL(t *testing.T, lineEnding string) {
+	t.Helper()
+	old := eol
+	eol = lineEnding
+	t.Cleanup(func() { eol = old })
+}
+
+// readOutput writes to a file in a temporary directory with write and returns
+// what it wrote.
+func readOutput(t *testing.T, name string, write func(filename string) error) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := write(filename); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func lineEndingProducts() []Product {
+	var product Product
+	product.ProductID = "9781"
+	product.Title = "Learning Go"
+	product.URL = "https://example.com/go"
+	product.Type = "book"
+	product.Language = "en"
+	product.Categories = [][]string{{"Programming", "Go"}}
+	product.CustomAttributes.Publishers = []string{"O'Reilly"}
+	product.CustomAttributes.PublicationDate = "2024-06-01"
+	product.Authors = []string{"Jon Bodner"}
+	return []Product{product}
+}
+
+func TestLineEndings(t *testing.T) {
+	products := lineEndingProducts()
+	csvWant := "Title,Publication Date,URL,Type,Language,Categories,Cover Image,Publishers,Authors,Format\n" +
+		"Learning Go,2024-06-01,https://example.com/go,book,en,Programming,,[O'Reilly],[Jon Bodner],ebook\n"
+	mdWant := "| Title | Publication Date | Categories |\n" +
+		"| --- | --- | --- |\n" +
+		"| [Learning Go](https://example.com/go) | 2024-06-01 | Programming |\n"
+	mdMonthWant := "## June 2024\n\n" + mdWant
+
+	for _, lineEnding := range []string{"\n", "\r\n"} {
+		setEOL(t, lineEnding)
+		ending := func(s string) string { return strings.ReplaceAll(s, "\n", lineEnding) }
+
+		got := readOutput(t, "list.csv", func(filename string) error { return writeCSV(filename, products, false) })
+		if want := ending(csvWant); got != want {
+			t.Errorf("CSV with eol %q:\ngot  %q\nwant %q", lineEnding, got, want)
+		}
+		got = readOutput(t, "list.md", func(filename string) error { return writeMarkdown(filename, products, "") })
+		if want := ending(mdWant); got != want {
+			t.Errorf("Markdown with eol %q:\ngot  %q\nwant %q", lineEnding, got, want)
+		}
+		got = readOutput(t, "months.md", func(filename string) error { return writeMarkdown(filename, products, "month") })
+		if want := ending(mdMonthWant); got != want {
+			t.Errorf("Markdown by month with eol %q:\ngot  %q\nwant %q", lineEnding, got, want)
+		}
+	}
+}
+
+func TestWriteJSONLineEndings(t *testing.T) {
+	products := lineEndingProducts()
+	products[0].Description = "first line\nsecond line"
+	products = append(products, products[0])
+
+	for _, compact := range []bool{false, true} {
+		setEOL(t, "\n")
+		lf := readOutput(t, "lf.json", func(filename string) error { return writeJSON(filename, products, false, compact) })
+		setEOL(t, "\r\n")
+		crlf := readOutput(t, "crlf.json", func(filename string) error { return writeJSON(filename, products, false, compact) })
+
+		if want := strings.ReplaceAll(lf, "\n", "\r\n"); crlf != want {
+			t.Errorf("compact=%t: crlf JSON:\ngot  %q\nwant %q", compact, crlf, want)
+		}
+		if !strings.Contains(crlf, `first line\nsecond line`) {
+			t.Errorf("compact=%t: newline inside a string was not kept escaped: %q", compact, crlf)
+		}
+	}
+}
+
+func TestWriteFacetsLineEndings(t *testing.T) {
+	setEOL(t, "\r\n")
+	facets := map[string]json.RawMessage{"type": json.RawMessage(`[{"name":"book","count":2}]`)}
+	got := readOutput(t, "facets.json", func(filename string) error { return writeFacets(filename, facets) })
+	if strings.Contains(strings.ReplaceAll(got, "\r\n", ""), "\n") {
+		t.Errorf("facets contain a bare newline: %q", got)
+	}
+	if !strings.HasSuffix(got, "}\r\n") {
+		t.Errorf("facets do not end with a crlf: %q", got)
+	}
+}
+
+func TestWriteJSONOmitEmptyRoundTrip(t *testing.T) {
+	full := lineEndingProducts()[0]
+	full.Series = "Learning"
+	var sparse Product
+	sparse.ProductID = "9782"
+	sparse.Title = "Untitled draft"
+	products := []Product{full, sparse}
+
+	for _, compact := range []bool{false, true} {
+		filename := filepath.Join(t.TempDir(), "products.json")
+		if err := writeJSON(filename, products, true, compact); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), `"description"`) {
+			t.Errorf("compact=%t: empty description was written: %s", compact, data)
+		}
+
+		got, err := readProductsJSON(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, products) {
+			t.Errorf("compact=%t: round trip:\ngot  %+v\nwant %+v", compact, got, products)
+		}
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr string
+	}{
+		{"csv", []string{"csv"}, ""},
+		{"csv,md", []string{"csv", "md"}, ""},
+		{" json , csv ", []string{"json", "csv"}, ""},
+		{"csv,md,csv", []string{"csv", "md"}, ""},
+		{"", nil, "no format selected"},
+		{" , ", nil, "no format selected"},
+		{"csv,bogus", nil, `unknown format "bogus"`},
+		{"CSV", nil, `unknown format "CSV"`},
+	}
+	for _, test := range tests {
+		got, err := parseFormats(test.value)
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("parseFormats(%q): %v", test.value, err)
+			} else if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseFormats(%q) = %q, want %q", test.value, got, test.want)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("parseFormats(%q) = %q, want an error", test.value, got)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("parseFormats(%q) error %q does not contain %q", test.value, err, test.wantErr)
+		}
+		for name := range outputFormats {
+			if !strings.Contains(err.Error(), name) {
+				t.Errorf("parseFormats(%q) error %q does not list the %s format", test.value, err, name)
+			}
+		}
+	}
+}
+
+func TestWriteSheetsTSV(t *testing.T) {
+	products := lineEndingProducts()
+	products[0].Title = "Tabs\tand\nnew\r\nlines\r"
+	products[0].Authors = []string{"A\tB"}
+	products[0].Categories = [][]string{{"Multi\nline"}}
+	products = append(products, lineEndingProducts()[0])
+
+	for _, lineEnding := range []string{"\n", "\r\n"} {
+		setEOL(t, lineEnding)
+		got := readOutput(t, "list.tsv", func(filename string) error { return writeSheetsTSV(filename, products) })
+
+		rows := strings.Split(strings.TrimSuffix(got, lineEnding), lineEnding)
+		if len(rows) != len(products)+1 {
+			t.Fatalf("eol %q: got %d rows, want %d: %q", lineEnding, len(rows), len(products)+1, got)
+		}
+		cells := len(csvHeader())
+		for i, row := range rows {
+			if strings.ContainsAny(row, "\r\n") {
+				t.Errorf("eol %q: row %d contains a line break: %q", lineEnding, i, row)
+			}
+			if n := len(strings.Split(row, "\t")); n != cells {
+				t.Errorf("eol %q: row %d has %d cells, want %d: %q", lineEnding, i, n, cells, row)
+			}
+		}
+		if title := strings.Split(rows[1], "\t")[0]; title != "Tabs and new lines " {
+			t.Errorf("eol %q: title cell = %q", lineEnding, title)
+		}
+	}
+}
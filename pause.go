@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// pauser lets page dispatch be paused and resumed while a run is in
+// progress. Requests already in flight are not affected.
+type pauser struct {
+	mu      sync.Mutex
+	paused  bool
+	resumed chan struct{} // closed on resume
+}
+
+// dispatch is the pauser consulted before each new page fetch.
+var dispatch = &pauser{}
+
+// toggle pauses a running dispatcher or resumes a paused one.
+func (p *pauser) toggle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused {
+		p.paused = false
+		close(p.resumed)
+		log.Printf("resumed page dispatch")
+		return
+	}
+	p.paused = true
+	p.resumed = make(chan struct{})
+	log.Printf("paused page dispatch; signal again to resume")
+}
+
+// wait blocks while dispatch is paused or until ctx ends.
+func (p *pauser) wait(ctx context.Context) {
+	p.mu.Lock()
+	paused, resumed := p.paused, p.resumed
+	p.mu.Unlock()
+
+	if !paused {
+		return
+	}
+	select {
+	case <-resumed:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// watchPauseSignals is a no-op where SIGUSR1 does not exist.
+func watchPauseSignals(p *pauser) {}
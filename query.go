@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// searchURL is the search API endpoint. It is a variable so tests can point
+// it at a local server.
+var searchURL = "https://www.oreilly.com/search/api/search/"
+
+// searchQuery is one search whose pages are fetched and merged into the
+// output.
+type searchQuery struct {
+	Query    string // search text; "*" matches everything
+	Type     string // product type, e.g. "book" or "video"
+	Language string // language code, e.g. "en"
+}
+
+// pageURL returns the search URL for one page of q.
+func (q searchQuery) pageURL(pageSize, page int) string {
+	params := url.Values{}
+	params.Set("q", q.Query)
+	params.Set("type", q.Type)
+	params.Set("order_by", "published_at")
+	params.Set("rows", strconv.Itoa(pageSize))
+	params.Set("language", q.Language)
+	params.Set("page", strconv.Itoa(page))
+	return searchURL + "?" + params.Encode()
+}
+
+// productKey identifies a product across pages and queries. IDs are only
+// unique within a type, so the type is part of the key.
+func productKey(product Product) string {
+	id := product.ProductID
+	if id == "" {
+		id = product.URL
+	}
+	return product.Type + "\x00" + id
+}
+
+// dedupeProducts drops repeated products, keeping the first occurrence, and
+// returns how many were dropped.
+func dedupeProducts(products []Product) ([]Product, int) {
+	seen := make(map[string]bool, len(products))
+	unique := products[:0:0]
+	for _, product := range products {
+		key := productKey(product)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, product)
+	}
+	return unique, len(products) - len(unique)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDedupeProducts(t *testing.T) {
+	type item struct{ id, typ, url, title string }
+	tests := []struct {
+		name    string
+		in      []item
+		want    []string // titles kept
+		dropped int
+	}{
+		{"book and video sharing an ID are kept", []item{
+			{"9781", "book", "", "book"}, {"9781", "video", "", "video"},
+		}, []string{"book", "video"}, 0},
+		{"same ID and type is dropped, first wins", []item{
+			{"9781", "book", "", "first"}, {"9782", "book", "", "other"}, {"9781", "book", "", "second"},
+		}, []string{"first", "other"}, 1},
+		{"empty ID falls back to URL", []item{
+			{"", "book", "https://example.com/a", "a"},
+			{"", "book", "https://example.com/b", "b"},
+			{"", "book", "https://example.com/a", "a again"},
+		}, []string{"a", "b"}, 1},
+		{"same URL in another type is kept", []item{
+			{"", "book", "https://example.com/a", "book"}, {"", "video", "https://example.com/a", "video"},
+		}, []string{"book", "video"}, 0},
+		{"empty", nil, nil, 0},
+	}
+	for _, test := range tests {
+		var products []Product
+		for _, it := range test.in {
+			products = append(products, Product{ProductID: it.id, Type: it.typ, URL: it.url, Title: it.title})
+		}
+		unique, dropped := dedupeProducts(products)
+		var titles []string
+		for _, product := range unique {
+			titles = append(titles, product.Title)
+		}
+		if !reflect.DeepEqual(titles, test.want) || dropped != test.dropped {
+			t.Errorf("%s: kept %q and dropped %d, want %q and %d", test.name, titles, dropped, test.want, test.dropped)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// categoryWeight is the number of products filed under a category.
+type categoryWeight struct {
+	Category string
+	Weight   int
+}
+
+// leafDepth is the category depth that picks the last, most specific level
+// of each category path.
+const leafDepth = 0
+
+// categoryDepth is the category level reports aggregate by, as set by
+// -report-category-depth; see categoryAt.
+var categoryDepth = 1
+
+// parseCategoryDepth parses a -report-category-depth value: a level counting
+// from 1 for the top level, or "leaf".
+func parseCategoryDepth(value string) (int, error) {
+	if value == "leaf" {
+		return leafDepth, nil
+	}
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth < 1 {
+		return 0, fmt.Errorf("want a level of at least 1 or leaf, got %q", value)
+	}
+	return depth, nil
+}
+
+// categoryAt returns the name at depth in a category path, where 1 is the top
+// level and leafDepth the last. A path shorter than depth falls back to its
+// deepest level. An empty path yields "".
+func categoryAt(category []string, depth int) string {
+	if len(category) == 0 {
+		return ""
+	}
+	if depth == leafDepth || depth > len(category) {
+		return category[len(category)-1]
+	}
+	return category[depth-1]
+}
+
+// countCategories counts the products filed under each category at the given
+// depth, as picked by categoryAt. A product is counted once per category even
+// if it lists the category more than once. The result is sorted by weight,
+// heaviest first, then by name.
+func countCategories(products []Product, depth int) []categoryWeight {
+	counts := make(map[string]int)
+	for _, product := range products {
+		seen := make(map[string]bool)
+		for _, category := range product.Categories {
+			name := categoryAt(category, depth)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			counts[name]++
+		}
+	}
+
+	weights := make([]categoryWeight, 0, len(counts))
+	for category, count := range counts {
+		weights = append(weights, categoryWeight{Category: category, Weight: count})
+	}
+	sort.Slice(weights, func(i, j int) bool {
+		if weights[i].Weight != weights[j].Weight {
+			return weights[i].Weight > weights[j].Weight
+		}
+		return weights[i].Category < weights[j].Category
+	})
+	return weights
+}
+
+// scaleWeight maps weight linearly from [lo, hi] onto 1-10 for font sizing.
+// When every weight is the same, all of them get the largest size.
+func scaleWeight(weight, lo, hi int) int {
+	if hi == lo {
+		return 10
+	}
+	return 1 + (weight-lo)*9/(hi-lo)
+}
+
+// writeTagCloud writes category weights as CSV, optionally with a 1-10 scale
+// column.
+func writeTagCloud(filename string, weights []categoryWeight, withScale bool) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newCSVWriter(file)
+	defer writer.Flush()
+
+	header := []string{"category", "weight"}
+	if withScale {
+		header = append(header, "scale")
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	// weights is sorted heaviest first
+	var lo, hi int
+	if len(weights) > 0 {
+		hi, lo = weights[0].Weight, weights[len(weights)-1].Weight
+	}
+	for _, w := range weights {
+		row := []string{w.Category, strconv.Itoa(w.Weight)}
+		if withScale {
+			row = append(row, strconv.Itoa(scaleWeight(w.Weight, lo, hi)))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// normalizeAuthor trims an author name and collapses internal whitespace.
+func normalizeAuthor(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// authorKey is the identity used to match the same author across products.
+func authorKey(name string) string {
+	return strings.ToLower(normalizeAuthor(name))
+}
+
+// authorCount is the number of products credited to an author.
+type authorCount struct {
+	Author string
+	Count  int
+}
+
+// countAuthors counts products per normalized author, most prolific first.
+// Each author on a multi-author product is credited once for it. The name
+// shown is the first spelling seen. When top is positive only that many
+// entries are returned.
+func countAuthors(products []Product, top int) []authorCount {
+	counts := make(map[string]*authorCount)
+	for _, product := range products {
+		seen := make(map[string]bool)
+		for _, author := range product.Authors {
+			key := authorKey(author)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			if counts[key] == nil {
+				counts[key] = &authorCount{Author: normalizeAuthor(author)}
+			}
+			counts[key].Count++
+		}
+	}
+
+	leaderboard := make([]authorCount, 0, len(counts))
+	for _, c := range counts {
+		leaderboard = append(leaderboard, *c)
+	}
+	sort.Slice(leaderboard, func(i, j int) bool {
+		if leaderboard[i].Count != leaderboard[j].Count {
+			return leaderboard[i].Count > leaderboard[j].Count
+		}
+		return leaderboard[i].Author < leaderboard[j].Author
+	})
+	if top > 0 && len(leaderboard) > top {
+		leaderboard = leaderboard[:top]
+	}
+	return leaderboard
+}
+
+// writeAuthorLeaderboardCSV writes the leaderboard as CSV.
+func writeAuthorLeaderboardCSV(filename string, leaderboard []authorCount) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newCSVWriter(file)
+	defer writer.Flush()
+
+	if err := writer.Write([]string{"Author", "Count"}); err != nil {
+		return err
+	}
+	for _, c := range leaderboard {
+		if err := writer.Write([]string{c.Author, strconv.Itoa(c.Count)}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeAuthorLeaderboardMarkdown writes the leaderboard as a ranked Markdown
+// table.
+func writeAuthorLeaderboardMarkdown(filename string, leaderboard []authorCount) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString("| Rank | Author | Count |" + eol + "| --- | --- | --- |" + eol); err != nil {
+		return err
+	}
+	for i, c := range leaderboard {
+		if _, err := fmt.Fprintf(file, "| %d | %s | %d |%s", i+1, c.Author, c.Count, eol); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFacets writes the search facets as indented JSON.
+func writeFacets(filename string, facets map[string]json.RawMessage) error {
+	data, err := json.MarshalIndent(facets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(jsonLineEndings(data), eol...), 0o644)
+}
+
+// writeTypeGroups writes a Markdown file with one section per product type,
+// largest first, each listing that type's products.
+func writeTypeGroups(filename string, products []Product) error {
+	byType := make(map[string][]Product)
+	var order []string
+	for _, product := range products {
+		if byType[product.Type] == nil {
+			order = append(order, product.Type)
+		}
+		byType[product.Type] = append(byType[product.Type], product)
+	}
+	sort.SliceStable(order, func(i, j int) bool { return len(byType[order[i]]) > len(byType[order[j]]) })
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for i, t := range order {
+		if i > 0 {
+			if _, err := file.WriteString(eol); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(file, "## %s (%d)%s%s", t, len(byType[t]), eol, eol); err != nil {
+			return err
+		}
+		if err := writeMarkdownTable(file, byType[t]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// shieldsEscape escapes text for the label or message part of a
+// shields.io static badge path.
+func shieldsEscape(text string) string {
+	text = strings.NewReplacer("-", "--", "_", "__", " ", "_").Replace(text)
+	return url.PathEscape(text)
+}
+
+// writeBadge writes a one-line Markdown snippet of shields.io badges showing
+// the book count and update date, for pasting into a README.
+func writeBadge(filename string, count int, updated string) error {
+	snippet := fmt.Sprintf("![books](https://img.shields.io/badge/%s-%s-blue) ![updated](https://img.shields.io/badge/%s-%s-lightgrey)%s",
+		shieldsEscape("O'Reilly books"), shieldsEscape(strconv.Itoa(count)),
+		shieldsEscape("last updated"), shieldsEscape(updated), eol)
+	return os.WriteFile(filename, []byte(snippet), 0o644)
+}
+
+// appendTotalTrend adds a date,total,unique_collected row to the CSV at
+// filename, creating it with a header if needed. total is the result count
+// the searches reported, summed over queries; uniqueCollected is how many
+// distinct products were actually fetched. The file is rewritten through a
+// temporary file and a rename, so an interrupted run never leaves it half
+// written, and keeps its mode, 0644 for a new file.
+func appendTotalTrend(filename, date string, total, uniqueCollected int) error {
+	existing, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	mode := fs.FileMode(0o644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if len(existing) == 0 {
+		existing = []byte("date,total,unique_collected" + eol)
+	} else if existing[len(existing)-1] != '\n' {
+		existing = append(existing, eol...)
+	}
+	if _, err := tmp.Write(existing); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := fmt.Fprintf(tmp, "%s,%d,%d%s", date, total, uniqueCollected, eol); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
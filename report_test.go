@@ -0,0 +1,167 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// categoryProduct returns a product filed under the given category paths.
+func categoryProduct(categories ...[]string) Product {
+	return Product{Categories: categories}
+}
+
+func TestCountCategories(t *testing.T) {
+	products := []Product{
+		categoryProduct([]string{"Programming", "Go"}, []string{"Programming", "Rust"}),
+		categoryProduct([]string{"Data", "SQL"}, []string{"Data", "SQL"}),
+		categoryProduct([]string{"Programming", "Go"}),
+		categoryProduct([]string{"Cloud"}, []string{}),
+		categoryProduct(),
+	}
+
+	tests := []struct {
+		name  string
+		depth int
+		want  []categoryWeight
+	}{
+		{"top level, ties by name", 1, []categoryWeight{
+			{"Programming", 2}, {"Cloud", 1}, {"Data", 1},
+		}},
+		{"second level falls back for short paths", 2, []categoryWeight{
+			{"Go", 2}, {"Cloud", 1}, {"Rust", 1}, {"SQL", 1},
+		}},
+		{"leaf", leafDepth, []categoryWeight{
+			{"Go", 2}, {"Cloud", 1}, {"Rust", 1}, {"SQL", 1},
+		}},
+	}
+	for _, test := range tests {
+		if got := countCategories(products, test.depth); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: countCategories = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestScaleWeight(t *testing.T) {
+	tests := []struct {
+		weight, lo, hi, want int
+	}{
+		{1, 1, 10, 1},
+		{10, 1, 10, 10},
+		{5, 1, 9, 5},
+		{3, 3, 3, 10},
+		{0, 0, 0, 10},
+	}
+	for _, test := range tests {
+		if got := scaleWeight(test.weight, test.lo, test.hi); got != test.want {
+			t.Errorf("scaleWeight(%d, %d, %d) = %d, want %d", test.weight, test.lo, test.hi, got, test.want)
+		}
+	}
+}
+
+func TestCountAuthors(t *testing.T) {
+	products := []Product{
+		{Authors: []string{"Martin  Kleppmann", "martin kleppmann"}},
+		{Authors: []string{" Martin Kleppmann ", "Chris Riccomini"}},
+		{Authors: []string{"Jon Bodner"}},
+		{Authors: []string{"Ann", ""}},
+		{Authors: []string{"  "}},
+		{},
+	}
+
+	tests := []struct {
+		name string
+		top  int
+		want []authorCount
+	}{
+		{"all", 0, []authorCount{
+			{"Martin Kleppmann", 2}, {"Ann", 1}, {"Chris Riccomini", 1}, {"Jon Bodner", 1},
+		}},
+		{"top 2 cuts a tie by name", 2, []authorCount{
+			{"Martin Kleppmann", 2}, {"Ann", 1},
+		}},
+		{"top above the count", 10, []authorCount{
+			{"Martin Kleppmann", 2}, {"Ann", 1}, {"Chris Riccomini", 1}, {"Jon Bodner", 1},
+		}},
+	}
+	for _, test := range tests {
+		if got := countAuthors(products, test.top); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: countAuthors = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestAppendTotalTrend(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "trend.csv")
+	if err := appendTotalTrend(filename, "2024-06-01", 100, 90); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendTotalTrend(filename, "2024-06-02", 110, 95); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "date,total,unique_collected\n2024-06-01,100,90\n2024-06-02,110,95\n"
+	if string(data) != want {
+		t.Errorf("trend file:\ngot  %q\nwant %q", data, want)
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o644 {
+		t.Errorf("new trend file mode = %o, want 644", mode)
+	}
+
+	if err := os.Chmod(filename, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendTotalTrend(filename, "2024-06-03", 120, 99); err != nil {
+		t.Fatal(err)
+	}
+	if info, err = os.Stat(filename); err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o640 {
+		t.Errorf("rewritten trend file mode = %o, want the existing 640", mode)
+	}
+}
+
+func TestCategoryDepth(t *testing.T) {
+	for value, want := range map[string]int{"1": 1, "2": 2, "5": 5, "leaf": leafDepth} {
+		if got, err := parseCategoryDepth(value); err != nil || got != want {
+			t.Errorf("parseCategoryDepth(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "0", "-1", "Leaf", "top"} {
+		if _, err := parseCategoryDepth(value); err == nil {
+			t.Errorf("parseCategoryDepth(%q) succeeded, want an error", value)
+		}
+	}
+
+	path := []string{"Programming", "Languages", "Go"}
+	tests := []struct {
+		category []string
+		depth    int
+		want     string
+	}{
+		{path, 1, "Programming"},
+		{path, 2, "Languages"},
+		{path, 3, "Go"},
+		{path, 4, "Go"},
+		{path, leafDepth, "Go"},
+		{[]string{"Data"}, 2, "Data"},
+		{nil, 1, ""},
+		{nil, leafDepth, ""},
+	}
+	for _, test := range tests {
+		if got := categoryAt(test.category, test.depth); got != test.want {
+			t.Errorf("categoryAt(%q, %d) = %q, want %q", test.category, test.depth, got, test.want)
+		}
+	}
+}
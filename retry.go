@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// retryPolicy controls how failed requests are retried. The delay before
+// retry n (counting from 0) is
+//
+//	d = min(base * 2^n, MaxDelay)
+//
+// where base is BaseDelay, or the status's own base delay from ByStatus. With
+// Jitter the actual wait is drawn uniformly from [d/2, d).
+type retryPolicy struct {
+	Retries   int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Jitter    bool
+
+	// ByStatus overrides Retries and BaseDelay for particular statuses.
+	// Any status listed is retried, even one that would not be by default.
+	ByStatus statusRetries
+}
+
+// statusRetry is the retry budget for one response status.
+type statusRetry struct {
+	Retries   int
+	BaseDelay time.Duration
+}
+
+// defaultStatusRetries wait longest on rate limiting and give up soonest on
+// plain server errors.
+func defaultStatusRetries() statusRetries {
+	return statusRetries{
+		http.StatusTooManyRequests:     {Retries: 5, BaseDelay: 10 * time.Second},
+		http.StatusServiceUnavailable:  {Retries: 3, BaseDelay: 2 * time.Second},
+		http.StatusInternalServerError: {Retries: 1, BaseDelay: time.Second},
+	}
+}
+
+// delay returns how long to wait before retry n with the default base delay.
+func (p retryPolicy) delay(n int) time.Duration {
+	return p.backoff(p.BaseDelay, n)
+}
+
+// backoff returns how long to wait before retry n starting from base.
+func (p retryPolicy) backoff(base time.Duration, n int) time.Duration {
+	d := base
+	for i := 0; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	if p.Jitter && d > 1 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)))
+	}
+	return d
+}
+
+// budget returns the retry count and base delay for a failure with the given
+// status, 0 meaning the request never got a response. ok is false if the
+// failure should not be retried at all.
+func (p retryPolicy) budget(status int) (retries int, base time.Duration, ok bool) {
+	if override, found := p.ByStatus[status]; found {
+		return override.Retries, override.BaseDelay, true
+	}
+	if status == 0 || status == http.StatusTooManyRequests || status >= 500 {
+		return p.Retries, p.BaseDelay, true
+	}
+	return 0, 0, false
+}
+
+// doWithRetry sends req, retrying transport errors and retryable statuses
+// according to retries, and gives up early if req's context ends. A non-2xx
+// response that is not retried, or is still failing after the last retry, is
+// returned as an error.
+//
+// The status and retry count are recorded on the span in req's context.
+func doWithRetry(client *http.Client, req *http.Request) (*http.Response, error) {
+	span := trace.SpanFromContext(req.Context())
+	for n := 0; ; n++ {
+		span.SetAttributes(attribute.Int("retries", n))
+		status := 0
+		resp, err := client.Do(req)
+		if err == nil {
+			status = resp.StatusCode
+			span.SetAttributes(attribute.Int("status", status))
+			if status >= 200 && status < 300 {
+				return resp, nil
+			}
+			resp.Body.Close()
+			err = fmt.Errorf("unexpected status %s", resp.Status)
+		}
+
+		limit, base, ok := retries.budget(status)
+		if !ok || n >= limit {
+			return nil, err
+		}
+		wait := retries.backoff(base, n)
+		log.Printf("retrying %s in %s (%d/%d): %v", req.URL, wait, n+1, limit, err)
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// statusRetries maps response statuses to their retry budgets. As a
+// flag.Value it accepts STATUS=RETRIES:BASE-DELAY, e.g. 429=5:10s, or a
+// comma-separated list of them, and may be repeated.
+type statusRetries map[int]statusRetry
+
+func (s statusRetries) String() string {
+	statuses := make([]int, 0, len(s))
+	for status := range s {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+
+	parts := make([]string, len(statuses))
+	for i, status := range statuses {
+		parts[i] = fmt.Sprintf("%d=%d:%s", status, s[status].Retries, s[status].BaseDelay)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s statusRetries) Set(value string) error {
+	for _, policy := range strings.Split(value, ",") {
+		if err := s.set(policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s statusRetries) set(value string) error {
+	statusText, rest, ok := strings.Cut(value, "=")
+	retriesText, delayText, ok2 := strings.Cut(rest, ":")
+	if !ok || !ok2 {
+		return fmt.Errorf("want STATUS=RETRIES:BASE-DELAY, got %q", value)
+	}
+
+	status, err := strconv.Atoi(statusText)
+	if err != nil || status < 100 || status > 599 {
+		return fmt.Errorf("invalid status %q", statusText)
+	}
+	n, err := strconv.Atoi(retriesText)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid retry count %q", retriesText)
+	}
+	delay, err := time.ParseDuration(delayText)
+	if err != nil || delay <= 0 {
+		return fmt.Errorf("invalid base delay %q", delayText)
+	}
+
+	s[status] = statusRetry{Retries: n, BaseDelay: delay}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultSeriesPatterns recognize well-known O'Reilly and Manning series in
+// titles. The first capture group is the series name.
+var defaultSeriesPatterns = []string{
+	`^(Head First)\b`,
+	`\b(Cookbook)\b`,
+	`\b(in Action)\b`,
+	`\b(Pocket Reference)\b`,
+	`\b(The Definitive Guide)\b`,
+	`\b(Up (?:&|and) Running)\b`,
+	`\b(In a Nutshell)\b`,
+	`^(Learning) `,
+	`^(Programming) `,
+}
+
+// compileSeriesPatterns compiles series patterns, as found in
+// defaultSeriesPatterns or a -series-patterns file.
+func compileSeriesPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// inferSeries returns the series a title belongs to according to the first
+// matching pattern, or "" if none match. The series name is the pattern's
+// first capture group, or the whole match if it has none.
+func inferSeries(title string, patterns []*regexp.Regexp) string {
+	for _, re := range patterns {
+		m := re.FindStringSubmatch(title)
+		if m == nil {
+			continue
+		}
+		if len(m) > 1 {
+			return m[1]
+		}
+		return m[0]
+	}
+	return ""
+}
+
+// seriesFilter keeps products in the named series, ignoring case.
+func seriesFilter(name string) filter {
+	return filter{
+		name: "series",
+		keep: func(product Product) bool {
+			return strings.EqualFold(product.Series, name)
+		},
+	}
+}
+
+// writeSeriesReport writes a Markdown file with one section per series,
+// largest first, listing its books oldest first so they read in order.
+func writeSeriesReport(filename string, products []Product) error {
+	bySeries := make(map[string][]Product)
+	for _, product := range products {
+		if product.Series != "" {
+			bySeries[product.Series] = append(bySeries[product.Series], product)
+		}
+	}
+	names := make([]string, 0, len(bySeries))
+	for name := range bySeries {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(bySeries[names[i]]) != len(bySeries[names[j]]) {
+			return len(bySeries[names[i]]) > len(bySeries[names[j]])
+		}
+		return names[i] < names[j]
+	})
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for i, name := range names {
+		books := bySeries[name]
+		sort.SliceStable(books, func(a, b int) bool { return displayDate(books[a]) < displayDate(books[b]) })
+
+		if i > 0 {
+			if _, err := file.WriteString(eol); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(file, "## %s (%d)%s%s", name, len(books), eol, eol); err != nil {
+			return err
+		}
+		for n, book := range books {
+			if _, err := fmt.Fprintf(file, "%d. [%s](%s) (%s)%s", n+1, book.Title, book.URL, displayDate(book), eol); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
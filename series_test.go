@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestInferSeries(t *testing.T) {
+	defaults, err := compileSeriesPatterns(defaultSeriesPatterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom, err := compileSeriesPatterns([]string{`Missing Manual`, `^(Essential) `, `^Essential (\w+)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		title  string
+		custom bool
+		want   string
+	}{
+		{"head first", "Head First Go", false, "Head First"},
+		{"cookbook", "Python Cookbook, 3rd Edition", false, "Cookbook"},
+		{"up and running", "Kubernetes: Up and Running", false, "Up and Running"},
+		{"up & running", "Docker: Up & Running", false, "Up & Running"},
+		{"definitive guide", "Kafka: The Definitive Guide", false, "The Definitive Guide"},
+		{"no series", "Designing Data-Intensive Applications", false, ""},
+		{"anchored pattern mid-title", "Deep Learning with PyTorch", false, ""},
+		{"first match wins", "Learning Go Cookbook", false, "Cookbook"},
+		{"no capture group uses the match", "Excel: The Missing Manual", true, "Missing Manual"},
+		{"first custom match wins", "Essential Kafka", true, "Essential"},
+	}
+	for _, test := range tests {
+		patterns := defaults
+		if test.custom {
+			patterns = custom
+		}
+		if got := inferSeries(test.title, patterns); got != test.want {
+			t.Errorf("%s: inferSeries(%q) = %q, want %q", test.name, test.title, got, test.want)
+		}
+	}
+
+	if got := inferSeries("Head First Go", nil); got != "" {
+		t.Errorf("inferSeries with no patterns = %q, want \"\"", got)
+	}
+	if _, err := compileSeriesPatterns([]string{`(unclosed`}); err == nil {
+		t.Error("compileSeriesPatterns accepted an invalid pattern")
+	}
+}
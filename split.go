@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// splitKeys returns the values a product is filed under when splitting the
+// product list, e.g. its language. A product may be filed under several.
+type splitKeys func(Product) []string
+
+// splitters maps each -split-by value to how it files products.
+var splitters = map[string]splitKeys{
+	"language": func(product Product) []string { return []string{product.Language} },
+	"type":     func(product Product) []string { return []string{product.Type} },
+	"category": func(product Product) []string {
+		var names []string
+		for _, category := range product.Categories {
+			if name := categoryAt(category, categoryDepth); name != "" {
+				names = append(names, name)
+			}
+		}
+		return names
+	},
+}
+
+// splitGroup is the products filed under one file name key.
+type splitGroup struct {
+	Key      string
+	Products []Product
+}
+
+// splitFileChars matches runs of characters not kept in split file name keys.
+var splitFileChars = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// reservedSplitKeys are the name parts of the other oreilly-<name>-<date>
+// outputs. A split value that maps to one of them gets a "-products" suffix
+// so its file and the report do not collide.
+var reservedSplitKeys = map[string]bool{
+	"book-list":          true,
+	"tag-cloud":          true,
+	"author-leaderboard": true,
+	"by-type":            true,
+	"series":             true,
+	"broken-covers":      true,
+}
+
+// splitFileSymbols spells out symbols that tell names like C, C++ and C# apart.
+var splitFileSymbols = strings.NewReplacer("+", "plus", "#", "sharp")
+
+// splitFileKey turns a split value into the key part of a file name, e.g.
+// "Software Development" into "software-development" and "C++" into
+// "cplusplus". Letters and digits outside ASCII are kept. A value with none
+// at all gets a short hash of itself, so distinct values stay apart, and an
+// empty value becomes "unknown". Keys of other outputs are avoided; see
+// reservedSplitKeys.
+func splitFileKey(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	key := splitFileSymbols.Replace(strings.ToLower(value))
+	key = strings.Trim(splitFileChars.ReplaceAllString(key, "-"), "-")
+	if key == "" {
+		sum := sha256.Sum256([]byte(value))
+		return "x" + hex.EncodeToString(sum[:4])
+	}
+	if reservedSplitKeys[key] {
+		return key + "-products"
+	}
+	return key
+}
+
+// splitProducts files products by keys, keeping their order within each
+// group, and returns the groups sorted by key. Values that map to the same
+// file name key share a group, which holds each product at most once.
+func splitProducts(products []Product, keys splitKeys) []splitGroup {
+	index := make(map[string]int)
+	var groups []splitGroup
+	for _, product := range products {
+		values := keys(product)
+		if len(values) == 0 {
+			values = []string{""}
+		}
+		seen := make(map[string]bool)
+		for _, value := range values {
+			key := splitFileKey(value)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			i, ok := index[key]
+			if !ok {
+				i = len(groups)
+				index[key] = i
+				groups = append(groups, splitGroup{Key: key})
+			}
+			groups[i].Products = append(groups[i].Products, product)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Key < groups[j].Key })
+	return groups
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitFileKey(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"Software Development", "software-development"},
+		{"C++", "cplusplus"},
+		{"C#", "csharp"},
+		{"C", "c"},
+		{"en", "en"},
+		{"", "unknown"},
+		{"日本語", "日本語"},
+		{"Programación", "programación"},
+		{"Tag Cloud", "tag-cloud-products"},
+		{"Series", "series-products"},
+		{"Book List", "book-list-products"},
+	}
+	for _, test := range tests {
+		if got := splitFileKey(test.value); got != test.want {
+			t.Errorf("splitFileKey(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+
+	symbols, other := splitFileKey("---"), splitFileKey("***")
+	if !strings.HasPrefix(symbols, "x") || symbols == other || symbols == "unknown" {
+		t.Errorf("symbol-only values got keys %q and %q, want distinct hashes", symbols, other)
+	}
+	if splitFileKey("---") != symbols {
+		t.Errorf("splitFileKey is not stable for %q", "---")
+	}
+}
+
+func TestSplitProducts(t *testing.T) {
+	products := []Product{
+		{ProductID: "1", Language: "en", Categories: [][]string{{"Software Development", "Go"}, {"C++"}, {"c++"}}},
+		{ProductID: "2", Language: "de", Categories: [][]string{{"日本語"}}},
+		{ProductID: "3"},
+		{ProductID: "4", Categories: [][]string{{"Programming"}, {}}},
+		{ProductID: "5", Categories: [][]string{{}, {""}}},
+	}
+	keys := func(groups []splitGroup) map[string][]string {
+		got := make(map[string][]string)
+		for _, group := range groups {
+			for _, product := range group.Products {
+				got[group.Key] = append(got[group.Key], product.ProductID)
+			}
+		}
+		return got
+	}
+
+	byLanguage := keys(splitProducts(products, splitters["language"]))
+	wantLanguage := map[string][]string{"en": {"1"}, "de": {"2"}, "unknown": {"3", "4", "5"}}
+	if !reflect.DeepEqual(byLanguage, wantLanguage) {
+		t.Errorf("split by language = %v, want %v", byLanguage, wantLanguage)
+	}
+
+	byCategory := keys(splitProducts(products, splitters["category"]))
+	wantCategory := map[string][]string{
+		"software-development": {"1"},
+		"cplusplus":            {"1"},
+		"日本語":                  {"2"},
+		"programming":          {"4"},
+		"unknown":              {"3", "5"},
+	}
+	if !reflect.DeepEqual(byCategory, wantCategory) {
+		t.Errorf("split by category = %v, want %v", byCategory, wantCategory)
+	}
+
+	groups := splitProducts(products, splitters["language"])
+	for i := 1; i < len(groups); i++ {
+		if groups[i-1].Key > groups[i].Key {
+			t.Errorf("groups not sorted by key: %q before %q", groups[i-1].Key, groups[i].Key)
+		}
+	}
+}